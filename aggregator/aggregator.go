@@ -2,72 +2,319 @@ package aggregator
 
 import (
 	"math/big"
+	"runtime"
 	"sort"
 	"sync"
+	"sync/atomic"
 
 	"github.com/omnibtc/go-hippo-sdk/aggregator/base"
+	"github.com/omnibtc/go-hippo-sdk/aggregator/coinlist"
 	"github.com/omnibtc/go-hippo-sdk/contract"
 	"github.com/omnibtc/go-hippo-sdk/types"
 )
 
+// minParallelQuoteRoutes is the least number of routes quoted in parallel
+const minParallelQuoteRoutes = 64
+
 type TradeAggregator struct {
-	app           contract.App
-	fetcher       types.SimulationKeys
-	poolProviders []base.TradingPoolProvider
-	allPolls      []base.TradingPool
-	xToAnyPools   map[string][]base.TradingPool
+	// coinListClient is the cached coin list, its coins are the intermediates of routes
+	coinListClient *coinlist.CoinListClient
+	fetcher        types.SimulationKeys
+	poolProviders  []base.TradingPoolProvider
+
+	// loadLock serialize whole loads, fetch included, so the registry is always from the last fetch
+	loadLock sync.Mutex
+	// updateLock serialize LoadAllPoolLists and SetLiquidityThreshold, which compute the new state without lock
+	// since the liquidity filter may search routes itself
+	updateLock sync.Mutex
+	// lock guard the pools and the settings quotes depend on, LoadAllPoolLists swap them while quotes are searched
+	lock     sync.RWMutex
+	registry *PoolRegistry
+	// generation is incremented whenever pools or settings change, quotes of an older generation are not cached
+	generation uint64
+	gasModel   *GasModel
+	// liquidityFilter drop pools under the liquidity threshold, nil if no threshold
+	liquidityFilter PoolFilter
+	// liquidityValued is true if the threshold values reserves with a CoinValuer, which may price coins
+	// from the loaded pools, so which pools pass can not be known before new pools are loaded
+	liquidityValued bool
+	quoteCache      *QuoteCache
+
+	routeCache *routeCache
+	// unsubscribe stop following the coin list, called by Close
+	unsubscribe func()
+	// reloadScheduled is 1 while a pool reload for added coins waits for loadLock
+	reloadScheduled int32
+	// closed is 1 after Close, a pending reload is dropped
+	closed int32
+	// quoteParallelism is the number of workers quoting routes, 0 is GOMAXPROCS, accessed atomically
+	quoteParallelism int32
 }
 
+// NewTradeAggregator load the coin list of app, it fails if the coin list can not be fetched
 func NewTradeAggregator(
 	app contract.App,
 	fetcher types.SimulationKeys,
+	poolProviders []base.TradingPoolProvider) (*TradeAggregator, error) {
+	coinListClient, err := coinlist.LoadCoinListClient(app)
+	if err != nil {
+		return nil, err
+	}
+	return NewTradeAggregatorWithCoinList(coinListClient, fetcher, poolProviders), nil
+}
+
+// NewTradeAggregatorWithCoinList share the coin list client with pool providers,
+// routes and quotes are searched again after the coin list changes and pools are reloaded when coins are added,
+// call Close to stop following the coin list
+func NewTradeAggregatorWithCoinList(
+	coinListClient *coinlist.CoinListClient,
+	fetcher types.SimulationKeys,
 	poolProviders []base.TradingPoolProvider) *TradeAggregator {
 	aggregator := &TradeAggregator{
-		app:           app,
-		fetcher:       fetcher,
-		poolProviders: poolProviders,
-		allPolls:      make([]base.TradingPool, 0),
-		xToAnyPools:   make(map[string][]base.TradingPool),
+		coinListClient: coinListClient,
+		fetcher:        fetcher,
+		poolProviders:  poolProviders,
+		registry:       NewPoolRegistry(nil),
+		routeCache:     newRouteCache(),
 	}
+	aggregator.unsubscribe = coinListClient.Subscribe(func(change coinlist.CoinListChange) {
+		aggregator.routeCache.clear()
+		aggregator.clearQuoteCache()
+		// pools of coins added by Reload were skipped by the last load, even by the first one if added meanwhile,
+		// discovered coins come from pools already being loaded
+		if len(change.Added) > 0 && !change.Discovered {
+			aggregator.scheduleReload()
+		}
+	})
 	aggregator.LoadAllPoolLists()
 	return aggregator
 }
 
-func (a *TradeAggregator) LoadAllPoolLists() {
+// Close stop following the coin list and drop a pending reload, the aggregator can still be used with its current pools
+func (a *TradeAggregator) Close() {
+	atomic.StoreInt32(&a.closed, 1)
+	a.unsubscribe()
+}
+
+// scheduleReload load the pools again in the background after the running load, a reload already pending covers the new change.
+// It does not block since coin list changes are notified while pools are loaded.
+func (a *TradeAggregator) scheduleReload() {
+	if !atomic.CompareAndSwapInt32(&a.reloadScheduled, 0, 1) {
+		return
+	}
+	go func() {
+		a.loadLock.Lock()
+		defer a.loadLock.Unlock()
+		// the fetch starts after this, a change notified from now on needs another reload
+		atomic.StoreInt32(&a.reloadScheduled, 0)
+		if atomic.LoadInt32(&a.closed) == 1 {
+			return
+		}
+		// pools loaded are used even if some fail, like the first load
+		_ = a.loadAllPoolLists()
+	}()
+}
+
+// CoinListClient return the coin list used to route, Reload it to pick up new coins
+func (a *TradeAggregator) CoinListClient() *coinlist.CoinListClient {
+	return a.coinListClient
+}
+
+// coinList return the cached coin list, it is fetched if it never loaded
+func (a *TradeAggregator) coinList() ([]types.CoinInfo, error) {
+	if !a.coinListClient.IsLoaded() {
+		if err := a.coinListClient.Reload(); err != nil {
+			return nil, err
+		}
+	}
+	return a.coinListClient.GetCoinInfoList(), nil
+}
+
+// LoadAllPoolLists load the pools of every provider, the pools loaded are used even if some resources fail,
+// the error is the first other error of a provider, eg. *base.ListError, or else the base.PoolErrors of providers which report them.
+// Loads run one at a time, so a slow fetch never replaces the pools of a later one.
+func (a *TradeAggregator) LoadAllPoolLists() error {
+	a.loadLock.Lock()
+	defer a.loadLock.Unlock()
+	return a.loadAllPoolLists()
+}
+
+func (a *TradeAggregator) loadAllPoolLists() error {
 	allPools := make([]base.TradingPool, 0)
+	poolErrors := make(base.PoolErrors, 0)
+	var loadErr error
 	wg := sync.WaitGroup{}
 	l := sync.Mutex{}
 	for _, p := range a.poolProviders {
 		wg.Add(1)
 		go func(p base.TradingPoolProvider) {
 			defer wg.Done()
-			pls := p.LoadPoolList()
-			if pls == nil {
-				return
+			var pls []base.TradingPool
+			var err error
+			if loader, ok := p.(base.PoolLoader); ok {
+				pls, err = loader.LoadPools()
+			} else {
+				pls = p.LoadPoolList()
 			}
 			l.Lock()
 			defer l.Unlock()
+			if errs, ok := err.(base.PoolErrors); ok {
+				poolErrors = append(poolErrors, errs...)
+			} else if err != nil && loadErr == nil {
+				loadErr = err
+			}
 			allPools = append(allPools, pls...)
 		}(p)
 	}
 	wg.Wait()
 
-	xToAnyPools := make(map[string][]base.TradingPool)
-	for _, p := range allPools {
-		fullName := p.XCoinInfo().TokenType.GetFullName()
-		if _, ok := xToAnyPools[fullName]; !ok {
-			xToAnyPools[fullName] = []base.TradingPool{p}
+	registry := NewPoolRegistry(allPools)
+	a.updateLock.Lock()
+	defer a.updateLock.Unlock()
+	old := a.Registry()
+	a.lock.RLock()
+	liquidityFilter, liquidityValued := a.liquidityFilter, a.liquidityValued
+	a.lock.RUnlock()
+	// a valuer prices coins from the registry being replaced, the topology of the new pools is unknown until they are live
+	var topology map[base.PoolId]poolUsage
+	sameRoutes := false
+	if !liquidityValued {
+		topology = routeTopology(registry, liquidityFilter)
+		// pools added, removed or crossing the liquidity threshold may make other routes for any pair
+		sameRoutes = sameTopology(routeTopology(old, liquidityFilter), topology)
+	}
+	changed := changedPools(old, registry)
+
+	a.lock.Lock()
+	a.generation++
+	if a.quoteCache != nil {
+		if sameRoutes {
+			a.quoteCache.invalidatePools(a.generation, changed)
 		} else {
-			ps := xToAnyPools[fullName]
-			ps = append(ps, p)
-			xToAnyPools[fullName] = ps
+			a.quoteCache.reset(a.generation)
 		}
 	}
-	a.xToAnyPools = xToAnyPools
-	a.allPolls = allPools
+	if liquidityValued {
+		a.routeCache.reset(nil)
+	} else {
+		a.routeCache.reload(topology, registry)
+	}
+	a.registry = registry
+	a.lock.Unlock()
+
+	if loadErr != nil {
+		return loadErr
+	}
+	if len(poolErrors) > 0 {
+		return poolErrors
+	}
+	return nil
+}
+
+// SetGasModel rank quotes by output minus gas cost, nil rank by output only
+func (a *TradeAggregator) SetGasModel(gasModel *GasModel) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.gasModel = gasModel
+	a.clearQuoteCacheLocked()
+}
+
+// SetLiquidityThreshold exclude pools under threshold from every route search,
+// if threshold has a Valuer cached routes and quotes are dropped on every reload since prices may change
+func (a *TradeAggregator) SetLiquidityThreshold(threshold LiquidityThreshold) {
+	a.updateLock.Lock()
+	defer a.updateLock.Unlock()
+	liquidityFilter := threshold.Filter()
+	topology := routeTopology(a.Registry(), liquidityFilter)
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.liquidityFilter = liquidityFilter
+	a.liquidityValued = threshold.Valuer != nil && threshold.MinValue.IsPositive()
+	a.routeCache.reset(topology)
+	a.clearQuoteCacheLocked()
+}
+
+// SetQuoteParallelism set the number of workers quoting routes in GetQuotes, 0 is GOMAXPROCS and 1 quotes sequentially
+func (a *TradeAggregator) SetQuoteParallelism(n int) {
+	atomic.StoreInt32(&a.quoteParallelism, int32(n))
+}
+
+// SetQuoteCache cache the results of GetQuotes in cache, nil disable caching.
+// Cached entries are dropped when LoadAllPoolLists finds the reserves of a pool on their routes changed,
+// or of any pool the search looked at when RouteOptions.TopK prunes routes. All entries are dropped when pools
+// are added or removed or cross the liquidity threshold, and on every reload if the threshold has a Valuer.
+// Calls with RouteOptions.PoolFilters are never cached. Gas costs of cached quotes are not updated when only pools
+// used to value gas change.
+func (a *TradeAggregator) SetQuoteCache(cache *QuoteCache) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.generation++
+	if cache != nil {
+		cache.reset(a.generation)
+	}
+	a.quoteCache = cache
+}
+
+// QuoteCacheStats return the statistics of the quote cache, zero if no cache is set
+func (a *TradeAggregator) QuoteCacheStats() QuoteCacheStats {
+	cache, _ := a.getQuoteCache()
+	if cache == nil {
+		return QuoteCacheStats{}
+	}
+	return cache.Stats()
+}
+
+func (a *TradeAggregator) clearQuoteCache() {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.clearQuoteCacheLocked()
+}
+
+// clearQuoteCacheLocked start a new generation with an empty quote cache, a.lock must be held
+func (a *TradeAggregator) clearQuoteCacheLocked() {
+	a.generation++
+	if a.quoteCache != nil {
+		a.quoteCache.reset(a.generation)
+	}
+}
+
+// getQuoteCache return the quote cache and the current generation
+func (a *TradeAggregator) getQuoteCache() (*QuoteCache, uint64) {
+	a.lock.RLock()
+	defer a.lock.RUnlock()
+	return a.quoteCache, a.generation
+}
+
+func (a *TradeAggregator) getGasModel() *GasModel {
+	a.lock.RLock()
+	defer a.lock.RUnlock()
+	return a.gasModel
+}
+
+func (a *TradeAggregator) getLiquidityFilter() PoolFilter {
+	a.lock.RLock()
+	defer a.lock.RUnlock()
+	return a.liquidityFilter
+}
+
+// Pools return all loaded pools
+func (a *TradeAggregator) Pools() []base.TradingPool {
+	return a.Registry().Pools()
+}
+
+// Registry return the index of loaded pools
+func (a *TradeAggregator) Registry() *PoolRegistry {
+	a.lock.RLock()
+	defer a.lock.RUnlock()
+	return a.registry
 }
 
 func (a *TradeAggregator) GetXtoYDirectSteps(x, y types.CoinInfo, requireRouteable bool) []base.TradeStep {
+	return a.getDirectSteps(x, y, requireRouteable, nil)
+}
+
+func (a *TradeAggregator) getDirectSteps(x, y types.CoinInfo, requireRouteable bool, filters []PoolFilter) []base.TradeStep {
 	xFullName := x.TokenType.GetFullName()
 	yFullName := y.TokenType.GetFullName()
 	if xFullName == yFullName {
@@ -75,38 +322,30 @@ func (a *TradeAggregator) GetXtoYDirectSteps(x, y types.CoinInfo, requireRouteab
 	}
 
 	steps := make([]base.TradeStep, 0)
-	if xToYCandidates, ok := a.xToAnyPools[xFullName]; ok {
-		for _, pool := range xToYCandidates {
-			if requireRouteable && !pool.IsRoutable() {
-				continue
-			}
-
-			if pool.YCoinInfo().TokenType.GetFullName() == yFullName {
-				steps = append(steps, base.NewTradeStep(pool, true))
-			}
+	for _, pool := range a.Registry().GetPoolsByPair(x.TokenType, y.TokenType) {
+		if requireRouteable && !pool.IsRoutable() {
+			continue
 		}
-	}
-	if yToXCandidates, ok := a.xToAnyPools[yFullName]; ok {
-		for _, pool := range yToXCandidates {
-			if requireRouteable && !pool.IsRoutable() {
-				continue
-			}
-			if pool.YCoinInfo().TokenType.GetFullName() == xFullName {
-				steps = append(steps, base.NewTradeStep(pool, false))
-			}
+		if !matchPoolFilters(pool, filters) {
+			continue
 		}
+		steps = append(steps, base.NewTradeStep(pool, pool.XCoinInfo().TokenType.GetFullName() == xFullName))
 	}
 
 	return steps
 }
 
 func (a *TradeAggregator) GetOneStepRoutes(x, y types.CoinInfo) []base.TradeRoute {
+	return a.getOneStepRoutes(x, y, nil)
+}
+
+func (a *TradeAggregator) getOneStepRoutes(x, y types.CoinInfo, filters []PoolFilter) []base.TradeRoute {
 	xFullName := x.TokenType.GetFullName()
 	if xFullName == y.TokenType.GetFullName() {
 		panic("cannot swap same token")
 	}
 
-	steps := a.GetXtoYDirectSteps(x, y, false)
+	steps := a.getDirectSteps(x, y, false, filters)
 	routes := make([]base.TradeRoute, 0)
 	for _, step := range steps {
 		routes = append(routes, base.NewTradeRoute([]base.TradeStep{step}))
@@ -115,27 +354,31 @@ func (a *TradeAggregator) GetOneStepRoutes(x, y types.CoinInfo) []base.TradeRout
 }
 
 func (a *TradeAggregator) GetTwoStepRoutes(x, y types.CoinInfo) ([]base.TradeRoute, error) {
-	xFullName := x.TokenType.GetFullName()
-	yFullName := y.TokenType.GetFullName()
-	result := make([]base.TradeRoute, 0)
-	fullList, err := a.app.CoinList.QueryFetchFullList()
+	fullList, err := a.coinList()
 	if err != nil {
 		return nil, err
 	}
-	for _, k := range fullList {
+	return a.getTwoStepRoutes(x, y, fullList, nil), nil
+}
+
+func (a *TradeAggregator) getTwoStepRoutes(x, y types.CoinInfo, intermediates []types.CoinInfo, filters []PoolFilter) []base.TradeRoute {
+	xFullName := x.TokenType.GetFullName()
+	yFullName := y.TokenType.GetFullName()
+	result := make([]base.TradeRoute, 0)
+	for _, k := range intermediates {
 		kFullName := k.TokenType.GetFullName()
 		if kFullName == xFullName || kFullName == yFullName {
 			continue
 		}
 
 		// x-to-k
-		xTokSteps := a.GetXtoYDirectSteps(x, k, true)
+		xTokSteps := a.getDirectSteps(x, k, true, filters)
 		if len(xTokSteps) == 0 {
 			continue
 		}
 
 		// k-to-y
-		kToySteps := a.GetXtoYDirectSteps(k, y, true)
+		kToySteps := a.getDirectSteps(k, y, true, filters)
 		if len(kToySteps) == 0 {
 			continue
 		}
@@ -148,29 +391,33 @@ func (a *TradeAggregator) GetTwoStepRoutes(x, y types.CoinInfo) ([]base.TradeRou
 			}
 		}
 	}
-	return result, nil
+	return result
 }
 
 func (a *TradeAggregator) GetThreeStepRoutes(x, y types.CoinInfo) ([]base.TradeRoute, error) {
-	xFullName := x.TokenType.GetFullName()
-	yFullName := y.TokenType.GetFullName()
-	result := make([]base.TradeRoute, 0)
-	fullList, err := a.app.CoinList.QueryFetchFullList()
+	fullList, err := a.coinList()
 	if err != nil {
 		return nil, err
 	}
-	for _, k := range fullList {
+	return a.getThreeStepRoutes(x, y, fullList, nil), nil
+}
+
+func (a *TradeAggregator) getThreeStepRoutes(x, y types.CoinInfo, intermediates []types.CoinInfo, filters []PoolFilter) []base.TradeRoute {
+	xFullName := x.TokenType.GetFullName()
+	yFullName := y.TokenType.GetFullName()
+	result := make([]base.TradeRoute, 0)
+	for _, k := range intermediates {
 		kFullName := k.TokenType.GetFullName()
 		if kFullName == xFullName || kFullName == yFullName {
 			continue
 		}
 
 		// x-to-k 2steps
-		xtoKRoutes, err := a.GetTwoStepRoutes(x, k)
-		if err != nil || len(xtoKRoutes) == 0 {
+		xtoKRoutes := a.getTwoStepRoutes(x, k, intermediates, filters)
+		if len(xtoKRoutes) == 0 {
 			continue
 		}
-		kToYSteps := a.GetXtoYDirectSteps(k, y, true)
+		kToYSteps := a.getDirectSteps(k, y, true, filters)
 		if len(kToYSteps) == 0 {
 			continue
 		}
@@ -184,30 +431,59 @@ func (a *TradeAggregator) GetThreeStepRoutes(x, y types.CoinInfo) ([]base.TradeR
 			}
 		}
 	}
-	return result, nil
+	return result
+}
+
+func (a *TradeAggregator) GetAllRoutes(x, y types.CoinInfo, opts RouteOptions) ([]base.TradeRoute, error) {
+	return a.getAllRoutes(x, y, opts, nil, nil)
 }
 
-func (a *TradeAggregator) GetAllRoutes(x, y types.CoinInfo, maxSteps int, allowRoundTrip bool) ([]base.TradeRoute, error) {
+// getAllRoutes search routes, probe is the input amount used to prune partial paths when opts.TopK is set,
+// recorder, if not nil, records every pool the pruned search looks at
+func (a *TradeAggregator) getAllRoutes(x, y types.CoinInfo, opts RouteOptions, probe *big.Int, recorder *poolRecorder) ([]base.TradeRoute, error) {
+	maxSteps := opts.getMaxSteps()
+	filters := opts.poolFilters()
+	if recorder != nil {
+		filters = append([]PoolFilter{recorder.filter}, filters...)
+	}
+	if liquidityFilter := a.getLiquidityFilter(); liquidityFilter != nil {
+		filters = append(filters, liquidityFilter)
+	}
+	if opts.TopK > 0 && probe != nil {
+		fullList, err := a.coinList()
+		if err != nil {
+			return nil, err
+		}
+		return a.getTopKRoutes(x, y, maxSteps, opts.filterIntermediates(fullList), filters, probe, opts.TopK), nil
+	}
+	// routes of the full search only change with topology, see routeCache
+	cacheKey, cacheable := routeCacheKey(x, y, opts)
+	var generation uint64
+	if cacheable {
+		var routes []base.TradeRoute
+		var ok bool
+		if routes, generation, ok = a.routeCache.get(cacheKey); ok {
+			return routes, nil
+		}
+	}
+
 	allRoutes := make([]base.TradeRoute, 0)
 	if maxSteps >= 1 {
-		rs := a.GetOneStepRoutes(x, y)
+		rs := a.getOneStepRoutes(x, y, filters)
 		allRoutes = append(allRoutes, rs...)
 	}
 	if maxSteps >= 2 {
-		if rs, err := a.GetTwoStepRoutes(x, y); err != nil {
+		fullList, err := a.coinList()
+		if err != nil {
 			return nil, err
-		} else {
-			allRoutes = append(allRoutes, rs...)
 		}
-	}
-	if maxSteps >= 3 {
-		if rs, err := a.GetThreeStepRoutes(x, y); err != nil {
-			return nil, err
-		} else {
-			allRoutes = append(allRoutes, rs...)
+		intermediates := opts.filterIntermediates(fullList)
+		allRoutes = append(allRoutes, a.getTwoStepRoutes(x, y, intermediates, filters)...)
+		if maxSteps >= 3 {
+			allRoutes = append(allRoutes, a.getThreeStepRoutes(x, y, intermediates, filters)...)
 		}
 	}
-	if !allowRoundTrip {
+	if !opts.AllowRoundTrip {
 		result := make([]base.TradeRoute, 0, len(allRoutes))
 		for _, item := range allRoutes {
 			if item.HasRoundTrip() {
@@ -215,32 +491,107 @@ func (a *TradeAggregator) GetAllRoutes(x, y types.CoinInfo, maxSteps int, allowR
 			}
 			result = append(result, item)
 		}
-		return result, nil
+		allRoutes = result
+	}
+	if cacheable {
+		a.routeCache.put(cacheKey, generation, allRoutes)
 	}
 	return allRoutes, nil
 }
 
-func (a *TradeAggregator) GetQuotes(inputAmount *big.Int, x, y types.CoinInfo, maxSteps int, reloadState bool, allowRoundTrip bool) ([]*base.RouteAndQuote, error) {
-	routes, err := a.GetAllRoutes(x, y, maxSteps, allowRoundTrip)
+// GetQuotes quote every route from x to y allowed by opts,
+// sorted by net output amount which is the output amount minus gas cost if a gas model is set
+// The quote cache, if set, returns the cached quotes of the same amount, other amounts of the same bucket
+// are quoted again on the routes cached for the first amount.
+func (a *TradeAggregator) GetQuotes(inputAmount *big.Int, x, y types.CoinInfo, opts RouteOptions) ([]*base.RouteAndQuote, error) {
+	var cacheKey string
+	var recorder *poolRecorder
+	cacheable := false
+	quoteCache, generation := a.getQuoteCache()
+	if quoteCache != nil {
+		cacheKey, cacheable = quoteCache.key(inputAmount, x, y, opts)
+		if cacheable {
+			if quotes, routes, ok := quoteCache.get(cacheKey, inputAmount); ok {
+				if quotes == nil {
+					// the routes are cached for another amount of the bucket
+					quotes = a.rankQuotes(routes, inputAmount, y)
+				}
+				return quotes, nil
+			}
+			if opts.TopK > 0 {
+				// routes of pruned pools are not in the result, but the quotes change with them
+				recorder = newPoolRecorder()
+			}
+		}
+	}
+
+	routes, err := a.getAllRoutes(x, y, opts, inputAmount, recorder)
 	if err != nil {
 		return nil, err
 	}
 
-	result := make([]*base.RouteAndQuote, len(routes))
-	for i, route := range routes {
-		result[i] = &base.RouteAndQuote{
-			Route: route,
-			Quote: route.GetQuote(inputAmount),
+	result := a.rankQuotes(routes, inputAmount, y)
+	if cacheable {
+		var candidates map[base.PoolId]struct{}
+		if recorder != nil {
+			candidates = recorder.ids
 		}
+		quoteCache.put(cacheKey, generation, result, candidates)
 	}
-	sort.Slice(result, func(i, j int) bool {
-		return ((*big.Int)(result[i].Quote.OutputAmount)).Cmp(result[j].Quote.OutputAmount) >= 0
-	})
 	return result, nil
 }
 
-func (a *TradeAggregator) GetBestQuote(inputAmount *big.Int, x, y types.CoinInfo, maxSteps int, reloadState bool, allowRoundTrip bool) (*base.RouteAndQuote, error) {
-	quotes, err := a.GetQuotes(inputAmount, x, y, maxSteps, reloadState, allowRoundTrip)
+// rankQuotes quote routes to y and sort them by net output
+func (a *TradeAggregator) rankQuotes(routes []base.TradeRoute, inputAmount *big.Int, y types.CoinInfo) []*base.RouteAndQuote {
+	result := a.quoteRoutes(routes, inputAmount)
+	a.applyGasCost(result, y)
+	// stable sort keeps route order among equal quotes, so results are deterministic
+	sort.SliceStable(result, func(i, j int) bool {
+		return ((*big.Int)(result[i].NetOutputAmount)).Cmp(result[j].NetOutputAmount) > 0
+	})
+	return result
+}
+
+// quoteRoutes quote routes with a bounded number of workers, result keeps the order of routes
+func (a *TradeAggregator) quoteRoutes(routes []base.TradeRoute, inputAmount *big.Int) []*base.RouteAndQuote {
+	result := make([]*base.RouteAndQuote, len(routes))
+	quote := func(from, to int) {
+		for i := from; i < to; i++ {
+			result[i] = &base.RouteAndQuote{
+				Route: routes[i],
+				Quote: routes[i].GetQuote(inputAmount),
+			}
+		}
+	}
+
+	workers := int(atomic.LoadInt32(&a.quoteParallelism))
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	// few routes are faster to quote than to start workers
+	if workers == 1 || len(routes) < minParallelQuoteRoutes {
+		quote(0, len(routes))
+		return result
+	}
+	chunk := (len(routes) + workers - 1) / workers
+	wg := sync.WaitGroup{}
+	for from := 0; from < len(routes); from += chunk {
+		to := from + chunk
+		if to > len(routes) {
+			to = len(routes)
+		}
+		wg.Add(1)
+		go func(from, to int) {
+			defer wg.Done()
+			quote(from, to)
+		}(from, to)
+	}
+	wg.Wait()
+	return result
+}
+
+func (a *TradeAggregator) GetBestQuote(inputAmount *big.Int, x, y types.CoinInfo, opts RouteOptions) (*base.RouteAndQuote, error) {
+	quotes, err := a.GetQuotes(inputAmount, x, y, opts)
 	if err != nil {
 		return nil, err
 	}
@@ -249,3 +600,63 @@ func (a *TradeAggregator) GetBestQuote(inputAmount *big.Int, x, y types.CoinInfo
 	}
 	return quotes[0], nil
 }
+
+// applyGasCost fill gas cost and net output of quotes, which all output y
+func (a *TradeAggregator) applyGasCost(quotes []*base.RouteAndQuote, y types.CoinInfo) {
+	for _, q := range quotes {
+		q.NetOutputAmount = q.Quote.OutputAmount
+	}
+	gasModel := a.getGasModel()
+	if gasModel == nil {
+		return
+	}
+	// routes with same steps cost same gas, convert each cost once
+	converted := make(map[string]*big.Int)
+	for _, q := range quotes {
+		cost := gasModel.EstimateRouteGas(&q.Route)
+		q.GasCost = cost
+		costInY, ok := converted[cost.String()]
+		if !ok {
+			costInY = a.convertGasCost(cost, y)
+			converted[cost.String()] = costInY
+		}
+		if costInY == nil {
+			continue
+		}
+		q.NetOutputAmount = big.NewInt(0).Sub(q.Quote.OutputAmount, costInY)
+	}
+}
+
+// convertGasCost value octas in y with the best route of at most 2 steps, nil if APT can not be swapped to y
+func (a *TradeAggregator) convertGasCost(octas *big.Int, y types.CoinInfo) *big.Int {
+	if y.TokenType.GetFullName() == AptosCoinFullName {
+		return octas
+	}
+	apt, ok := a.getLoadedCoinInfo(AptosCoinFullName)
+	if !ok {
+		return nil
+	}
+	routes, err := a.GetAllRoutes(apt, y, RouteOptions{MaxSteps: 2})
+	if err != nil {
+		return nil
+	}
+	var best *big.Int
+	for _, route := range routes {
+		out := route.GetQuote(octas).OutputAmount
+		if best == nil || best.Cmp(out) < 0 {
+			best = out
+		}
+	}
+	return best
+}
+
+// getLoadedCoinInfo find the coin info of a coin traded by any loaded pool
+func (a *TradeAggregator) getLoadedCoinInfo(fullName string) (types.CoinInfo, bool) {
+	for _, p := range a.Registry().byCoin[fullName] {
+		if p.XCoinInfo().TokenType.GetFullName() == fullName {
+			return p.XCoinInfo(), true
+		}
+		return p.YCoinInfo(), true
+	}
+	return types.CoinInfo{}, false
+}
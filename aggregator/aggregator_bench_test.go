@@ -0,0 +1,279 @@
+package aggregator
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/coming-chat/go-aptos/aptostypes"
+	"github.com/omnibtc/go-hippo-sdk/aggregator/base"
+	"github.com/omnibtc/go-hippo-sdk/aggregator/coinlist"
+	"github.com/omnibtc/go-hippo-sdk/aggregator/obric"
+	"github.com/omnibtc/go-hippo-sdk/aggregator/pontem"
+	"github.com/omnibtc/go-hippo-sdk/contract"
+	"github.com/omnibtc/go-hippo-sdk/types"
+	"github.com/shopspring/decimal"
+)
+
+// newDenseMockAggregator build pools on two dexes between every pair of n coins,
+// which gives thousands of 3 step routes for n = 12
+func newDenseMockAggregator(n int) (*TradeAggregator, []types.CoinInfo) {
+	coins := make([]types.CoinInfo, n)
+	for i := range coins {
+		coins[i] = mockCoin(fmt.Sprintf("C%d", i))
+	}
+	pools := make([]base.TradingPool, 0)
+	id := 0
+	for i := 0; i < n; i++ {
+		for j := i + 1; j < n; j++ {
+			reserve := int64(1e12 + i*1e10 + j*1e9)
+			pools = append(pools,
+				newMockPool(base.Pancake, id, coins[i], coins[j], reserve, reserve+int64(j)*1e10),
+				newMockPool(base.Aux, id+1, coins[j], coins[i], reserve, reserve-int64(i)*1e10),
+			)
+			id += 2
+		}
+	}
+	return newMockAggregatorWithPools(coins, pools), coins
+}
+
+// TestTradeAggregator_ConcurrentReload is meant to run with -race,
+// quotes searched while pools are reloaded must never be cached for the new pools
+func TestTradeAggregator_ConcurrentReload(t *testing.T) {
+	a, coins := newDenseMockAggregator(6)
+	a.SetQuoteCache(NewQuoteCache(0, nil))
+	provider := a.poolProviders[0].(*mockProvider)
+	inputAmount := big.NewInt(1e8)
+	opts := RouteOptions{MaxSteps: 2}
+
+	stop := make(chan struct{})
+	wg := sync.WaitGroup{}
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				if _, err := a.GetQuotes(inputAmount, coins[w%len(coins)], coins[(w+1)%len(coins)], opts); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}(w)
+	}
+	for round := 1; round <= 20; round++ {
+		pools := make([]base.TradingPool, len(provider.pools))
+		for i, p := range provider.pools {
+			m := *p.(*mockPool)
+			m.reserveX = new(big.Int).Add(m.reserveX, big.NewInt(int64(round)*1e9))
+			pools[i] = &m
+		}
+		provider.pools = pools
+		a.LoadAllPoolLists()
+	}
+	close(stop)
+	wg.Wait()
+
+	registry := a.Registry()
+	for w := 0; w < 4; w++ {
+		quotes, err := a.GetQuotes(inputAmount, coins[w%len(coins)], coins[(w+1)%len(coins)], opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, q := range quotes {
+			for _, step := range q.Route.Steps {
+				if pool, ok := registry.GetPool(step.Pool.PoolId()); !ok || pool != step.Pool {
+					t.Fatalf("cached route uses stale pool %v", step.Pool.PoolId())
+				}
+			}
+			if fresh := (*big.Int)(q.Route.GetQuote(inputAmount).OutputAmount); fresh.Cmp(q.Quote.OutputAmount) != 0 {
+				t.Fatalf("cached quote %v, want %s", q.Quote.OutputAmount, fresh)
+			}
+		}
+	}
+}
+
+// recordedPools is the layout of testdata/mainnet_pools.json, the pool resources of each dex recorded from mainnet
+type recordedPools map[string]struct {
+	Owner     string                       `json:"owner"`
+	Resources []aptostypes.AccountResource `json:"resources"`
+}
+
+// newRecordedAggregator load the recorded pontem and obric pools with the embedded coin list,
+// the stable pontem pools are skipped
+func newRecordedAggregator(tb testing.TB) (*TradeAggregator, *coinlist.CoinListClient) {
+	data, err := os.ReadFile("testdata/mainnet_pools.json")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	var recorded recordedPools
+	if err = json.Unmarshal(data, &recorded); err != nil {
+		tb.Fatal(err)
+	}
+	coinListClient, err := coinlist.LoadCoinListClient(contract.App{CoinList: contract.NewEmbeddedCoinListApp()})
+	if err != nil {
+		tb.Fatal(err)
+	}
+	pontemProvider, err := pontem.NewPoolProvider(nil, recorded["pontem"].Owner, coinListClient, pontem.ModuleAddress)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	providers := []base.TradingPoolProvider{
+		pontemProvider,
+		obric.NewPoolProvider(nil, recorded["obric"].Owner, coinListClient),
+	}
+	for i, dex := range []string{"pontem", "obric"} {
+		providers[i].(*base.PoolProvider).SetResourceLoader(base.StaticResourceLoader(recorded[dex].Resources))
+	}
+	return NewTradeAggregatorWithCoinList(coinListClient, types.SimulationKeys{}, providers), coinListClient
+}
+
+func recordedCoin(tb testing.TB, coinListClient *coinlist.CoinListClient, fullName string) types.CoinInfo {
+	coin, ok := coinListClient.GetCoinInfoByFullName(fullName)
+	if !ok {
+		tb.Fatalf("coin %s not in coin list", fullName)
+	}
+	return coin
+}
+
+const (
+	recordedAPT    = "0x1::aptos_coin::AptosCoin"
+	recordedCeUSDT = "0x8d87a65ba30e09357fa2edea2c80dbac296e5dec2b18287113500b902942929d::celer_coin_manager::UsdtCoin"
+)
+
+func TestTradeAggregator_GetQuotes_Recorded(t *testing.T) {
+	a, coinListClient := newRecordedAggregator(t)
+	if len(a.Pools()) != 7 {
+		t.Fatalf("Pools() = %d, want 7", len(a.Pools()))
+	}
+	apt := recordedCoin(t, coinListClient, recordedAPT)
+	usdt := recordedCoin(t, coinListClient, recordedCeUSDT)
+	quotes, err := a.GetQuotes(apt.Unit(), apt, usdt, RouteOptions{MaxSteps: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(quotes) == 0 {
+		t.Fatal("GetQuotes() = no route")
+	}
+	// 1 APT is about 7.3 USD in the recorded pools
+	out := usdt.FromBaseUnits(quotes[0].Quote.OutputAmount)
+	if out.LessThan(decimal.NewFromInt(7)) || out.GreaterThan(decimal.NewFromInt(8)) {
+		t.Errorf("GetQuotes() best = %s, want about 7.3 USDT", quotes[0])
+	}
+	dexes := make(map[base.DexType]bool)
+	for _, q := range quotes {
+		for _, step := range q.Route.Steps {
+			dexes[step.Pool.DexType()] = true
+		}
+	}
+	if !dexes[base.Pontem] || !dexes[base.Obric] {
+		t.Errorf("GetQuotes() routes use dexes %v, want pontem and obric", dexes)
+	}
+}
+
+func TestTradeAggregator_GetQuotes_Parallel(t *testing.T) {
+	a, coins := newDenseMockAggregator(8)
+	inputAmount := big.NewInt(1e8)
+	opts := RouteOptions{MaxSteps: 3}
+	a.SetQuoteParallelism(1)
+	sequential, err := a.GetQuotes(inputAmount, coins[0], coins[1], opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sequential) < minParallelQuoteRoutes {
+		t.Fatalf("GetQuotes() = %d routes, want at least %d", len(sequential), minParallelQuoteRoutes)
+	}
+	a.SetQuoteParallelism(4)
+	parallel, err := a.GetQuotes(inputAmount, coins[0], coins[1], opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parallel) != len(sequential) {
+		t.Fatalf("parallel GetQuotes() = %d routes, want %d", len(parallel), len(sequential))
+	}
+	for i := range sequential {
+		if sequential[i].Route.Steps[0].Pool.PoolId() != parallel[i].Route.Steps[0].Pool.PoolId() ||
+			((*big.Int)(sequential[i].Quote.OutputAmount)).Cmp(parallel[i].Quote.OutputAmount) != 0 {
+			t.Fatalf("quote %d differs between sequential and parallel", i)
+		}
+	}
+}
+
+// BenchmarkTradeAggregator_GetQuotes compare quoting workers, the speedup needs multiple CPUs, eg. -cpu 1,4
+func BenchmarkTradeAggregator_GetQuotes(b *testing.B) {
+	a, coins := newDenseMockAggregator(12)
+	inputAmount := big.NewInt(1e8)
+	opts := RouteOptions{MaxSteps: 3}
+	for _, parallelism := range []int{1, 2, 4, 0} {
+		name := fmt.Sprintf("workers=%d", parallelism)
+		if parallelism == 0 {
+			name = "workers=GOMAXPROCS"
+		}
+		b.Run(name, func(b *testing.B) {
+			a.SetQuoteParallelism(parallelism)
+			// warm the route cache, so only quoting is measured
+			if _, err := a.GetQuotes(inputAmount, coins[0], coins[1], opts); err != nil {
+				b.Fatal(err)
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := a.GetQuotes(inputAmount, coins[0], coins[1], opts); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkTradeAggregator_GetAllRoutes(b *testing.B) {
+	a, coins := newDenseMockAggregator(12)
+	opts := RouteOptions{MaxSteps: 3}
+	b.Run("cached", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := a.GetAllRoutes(coins[0], coins[1], opts); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("uncached", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			a.routeCache.reset(nil)
+			if _, err := a.GetAllRoutes(coins[0], coins[1], opts); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// BenchmarkTradeAggregator_GetQuotes_Recorded quote APT to USDT through the recorded pontem and obric pools
+func BenchmarkTradeAggregator_GetQuotes_Recorded(b *testing.B) {
+	a, coinListClient := newRecordedAggregator(b)
+	apt := recordedCoin(b, coinListClient, recordedAPT)
+	usdt := recordedCoin(b, coinListClient, recordedCeUSDT)
+	inputAmount := apt.Unit()
+	opts := RouteOptions{MaxSteps: 3}
+	// routes are cached after the first search, so uncached measures quoting the recorded pools
+	b.Run("uncached", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := a.GetQuotes(inputAmount, apt, usdt, opts); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("cached", func(b *testing.B) {
+		a.SetQuoteCache(NewQuoteCache(0, nil))
+		defer a.SetQuoteCache(nil)
+		for i := 0; i < b.N; i++ {
+			if _, err := a.GetQuotes(inputAmount, apt, usdt, opts); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,923 @@
+package aggregator
+
+import (
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/omnibtc/go-hippo-sdk/aggregator/base"
+	"github.com/omnibtc/go-hippo-sdk/contract"
+	"github.com/omnibtc/go-hippo-sdk/types"
+	"github.com/omnibtc/go-hippo-sdk/util"
+	"github.com/shopspring/decimal"
+)
+
+// mockPool is a constant product pool with 0.3% fee
+type mockPool struct {
+	dex      base.DexType
+	id       int
+	x, y     types.CoinInfo
+	reserveX *big.Int
+	reserveY *big.Int
+}
+
+func (m *mockPool) PoolId() base.PoolId {
+	return base.PoolId{
+		Dex:          m.dex,
+		OwnerAddress: "0x1",
+		ResourceType: fmt.Sprintf("0x1::pool::Pool%d<%s, %s>", m.id, m.x.TokenType.GetFullName(), m.y.TokenType.GetFullName()),
+	}
+}
+func (m *mockPool) DexType() base.DexType     { return m.dex }
+func (m *mockPool) PoolType() base.PoolType   { return 0 }
+func (m *mockPool) IsRoutable() bool          { return true }
+func (m *mockPool) XCoinInfo() types.CoinInfo { return m.x }
+func (m *mockPool) YCoinInfo() types.CoinInfo { return m.y }
+func (m *mockPool) IsStateLoaded() bool       { return true }
+func (m *mockPool) Reserves() (reserveX, reserveY *big.Int) {
+	return m.reserveX, m.reserveY
+}
+func (m *mockPool) GetPrice() base.PriceType { panic("not implemented") }
+func (m *mockPool) GetTagE() types.TokenType { return types.U8 }
+func (m *mockPool) GetQuote(inputAmount base.TokenAmount, isXToY bool) base.QuoteType {
+	in, out := m.x, m.y
+	rin, rout := m.reserveX, m.reserveY
+	if !isXToY {
+		in, out = out, in
+		rin, rout = rout, rin
+	}
+	return base.QuoteType{
+		InputSymbol:  in.Symbol,
+		OutputSymbol: out.Symbol,
+		InputAmount:  inputAmount,
+		OutputAmount: util.GetCoinOutWithFees(inputAmount, rin, rout, 30, 10000),
+	}
+}
+func (m *mockPool) ConstantProductParams(isXToY bool) (base.ConstantProductParams, bool) {
+	return base.NewConstantProductParams(m.reserveX, m.reserveY, isXToY, 30), true
+}
+func (m *mockPool) MakePayload(input base.TokenAmount, minOut base.TokenAmount, isXToY bool) types.EntryFunctionPayload {
+	panic("not implemented")
+}
+
+type mockProvider struct {
+	pools []base.TradingPool
+	// loaded receives a value on every load if set
+	loaded chan struct{}
+	// loads wait for a value from wait if set
+	wait chan struct{}
+}
+
+func (m *mockProvider) LoadPoolList() []base.TradingPool {
+	if m.loaded != nil {
+		m.loaded <- struct{}{}
+	}
+	if m.wait != nil {
+		<-m.wait
+	}
+	return m.pools
+}
+
+func (m *mockProvider) SetResourceTypes(resourceTypes []string) {}
+
+func mockCoin(symbol string) types.CoinInfo {
+	return types.CoinInfo{
+		Name:      symbol,
+		Decimals:  8,
+		Symbol:    symbol,
+		TokenType: &types.StructTag{Address: "0x1", Module: "coin", Name: symbol},
+	}
+}
+
+func newMockPool(dex base.DexType, id int, x, y types.CoinInfo, reserveX, reserveY int64) *mockPool {
+	return &mockPool{
+		dex:      dex,
+		id:       id,
+		x:        x,
+		y:        y,
+		reserveX: big.NewInt(reserveX),
+		reserveY: big.NewInt(reserveY),
+	}
+}
+
+var (
+	coinA = mockCoin("A")
+	coinB = mockCoin("B")
+	coinC = mockCoin("C")
+	coinD = mockCoin("D")
+)
+
+// newMockAggregator build A-B on two dexes, A-C, C-B and B-D
+func newMockAggregator() *TradeAggregator {
+	coins := []types.CoinInfo{coinA, coinB, coinC, coinD}
+	pools := []base.TradingPool{
+		newMockPool(base.Pancake, 0, coinA, coinB, 1e12, 2e12),
+		newMockPool(base.Aux, 1, coinB, coinA, 1e10, 5e9),
+		newMockPool(base.AnimeSwap, 2, coinA, coinC, 1e12, 1e12),
+		newMockPool(base.Pontem, 3, coinC, coinB, 1e12, 2e12),
+		newMockPool(base.Pancake, 4, coinB, coinD, 1e12, 1e12),
+	}
+	return newMockAggregatorWithPools(coins, pools)
+}
+
+func newMockAggregatorWithPools(coins []types.CoinInfo, pools []base.TradingPool) *TradeAggregator {
+	a, err := NewTradeAggregator(
+		contract.App{CoinList: contract.NewCustomCoinListApp(coins)},
+		types.SimulationKeys{},
+		[]base.TradingPoolProvider{&mockProvider{pools: pools}},
+	)
+	if err != nil {
+		panic(err)
+	}
+	return a
+}
+
+func TestPoolRegistry(t *testing.T) {
+	a := newMockAggregator()
+	r := a.Registry()
+	if len(a.Pools()) != 5 {
+		t.Fatalf("Pools() = %d, want 5", len(a.Pools()))
+	}
+	if ab, ba := r.GetPoolsByPair(coinA.TokenType, coinB.TokenType), r.GetPoolsByPair(coinB.TokenType, coinA.TokenType); len(ab) != 2 || len(ba) != 2 {
+		t.Errorf("GetPoolsByPair() = %d, %d, want 2, 2", len(ab), len(ba))
+	}
+	if got := r.GetPoolsByDex(base.Pancake); len(got) != 2 {
+		t.Errorf("GetPoolsByDex() = %d, want 2", len(got))
+	}
+	if got := r.GetPoolsByCoin(coinB.TokenType); len(got) != 4 {
+		t.Errorf("GetPoolsByCoin() = %d, want 4", len(got))
+	}
+	p := a.Pools()[3]
+	if got, ok := r.GetPool(p.PoolId()); !ok || got != p {
+		t.Errorf("GetPool() = %v, %v", got, ok)
+	}
+}
+
+func TestTradeAggregator_GetQuotes_Filters(t *testing.T) {
+	a := newMockAggregator()
+	quotes, err := a.GetQuotes(big.NewInt(1e8), coinA, coinB, RouteOptions{MaxSteps: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(quotes) != 3 {
+		t.Fatalf("GetQuotes() = %d routes, want 3", len(quotes))
+	}
+
+	quotes, err = a.GetQuotes(big.NewInt(1e8), coinA, coinB, RouteOptions{MaxSteps: 3, DeniedDexes: []base.DexType{base.Pancake}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, q := range quotes {
+		for _, step := range q.Route.Steps {
+			if step.Pool.DexType() == base.Pancake {
+				t.Errorf("GetQuotes() route uses excluded dex")
+			}
+		}
+	}
+	if len(quotes) != 2 {
+		t.Errorf("GetQuotes() = %d routes, want 2", len(quotes))
+	}
+}
+
+func TestTradeAggregator_GetQuotes_Options(t *testing.T) {
+	a := newMockAggregator()
+	tests := []struct {
+		name string
+		opts RouteOptions
+		want int
+	}{
+		{name: "default", opts: RouteOptions{}, want: 3},
+		{name: "one step", opts: RouteOptions{MaxSteps: 1}, want: 2},
+		{name: "allowed dexes", opts: RouteOptions{AllowedDexes: []base.DexType{base.AnimeSwap, base.Pontem}}, want: 1},
+		{name: "intermediates", opts: RouteOptions{AllowedIntermediates: []types.TokenType{coinD.TokenType}}, want: 2},
+		{name: "pool filter", opts: RouteOptions{PoolFilters: []PoolFilter{ExcludePools(a.Pools()[1].PoolId())}}, want: 2},
+		{name: "round trip", opts: RouteOptions{AllowRoundTrip: true}, want: 7},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			quotes, err := a.GetQuotes(big.NewInt(1e8), coinA, coinB, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(quotes) != tt.want {
+				t.Errorf("GetQuotes() = %d routes, want %d", len(quotes), tt.want)
+			}
+		})
+	}
+}
+
+func TestTradeAggregator_GetQuotes_GasModel(t *testing.T) {
+	apt := types.CoinInfo{
+		Name:      "Aptos Coin",
+		Decimals:  8,
+		Symbol:    "APT",
+		TokenType: &types.StructTag{Address: "0x1", Module: "aptos_coin", Name: "AptosCoin"},
+	}
+	pools := []base.TradingPool{
+		// direct pool is a little shallower than the 2 step route
+		newMockPool(base.Pancake, 0, apt, coinB, 1e12, 0.99e12),
+		newMockPool(base.AnimeSwap, 1, apt, coinC, 1e12, 1e12),
+		newMockPool(base.AnimeSwap, 2, coinC, coinB, 1e12, 1.00001e12),
+	}
+	a, err := NewTradeAggregator(
+		contract.App{CoinList: contract.NewCustomCoinListApp([]types.CoinInfo{apt, coinB, coinC})},
+		types.SimulationKeys{},
+		[]base.TradingPoolProvider{&mockProvider{pools: pools}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := big.NewInt(1e6)
+	best, err := a.GetBestQuote(input, apt, coinB, RouteOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(best.Route.Steps) != 2 || best.GasCost != nil {
+		t.Fatalf("GetBestQuote() without gas model = %d steps", len(best.Route.Steps))
+	}
+
+	a.SetGasModel(NewGasModel(100))
+	best, err = a.GetBestQuote(input, apt, coinB, RouteOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(best.Route.Steps) != 1 {
+		t.Fatalf("GetBestQuote() with gas model = %d steps, want 1", len(best.Route.Steps))
+	}
+	if best.GasCost.Cmp(big.NewInt(800*100)) != 0 {
+		t.Errorf("GetBestQuote() gas cost = %s, want %d", best.GasCost, 800*100)
+	}
+	if ((*big.Int)(best.NetOutputAmount)).Cmp(best.Quote.OutputAmount) >= 0 {
+		t.Errorf("GetBestQuote() net output %v should be less than output %v", best.NetOutputAmount, best.Quote.OutputAmount)
+	}
+}
+
+func TestGasModel_Observe(t *testing.T) {
+	m := NewGasModel(100)
+	m.Observe(2, false, 4500)
+	if got := m.GasUnits(2, false); got != (2500*3+4500)/4 {
+		t.Errorf("GasUnits() = %d", got)
+	}
+	m.Observe(2, true, 1000)
+	if got := m.GasUnits(2, true); got != 1000 {
+		t.Errorf("GasUnits() = %d, want 1000", got)
+	}
+	if got := m.GasUnits(3, true); got != 3500 {
+		t.Errorf("GasUnits() = %d, want fallback 3500", got)
+	}
+}
+
+func TestTradeAggregator_GetQuotes_TopK(t *testing.T) {
+	pools := []base.TradingPool{
+		newMockPool(base.Pancake, 0, coinA, coinC, 1e12, 1e12),
+		newMockPool(base.Aux, 1, coinA, coinC, 1e12, 2e12),
+		newMockPool(base.AnimeSwap, 2, coinA, coinC, 1e12, 0.5e12),
+		newMockPool(base.Pontem, 3, coinC, coinB, 1e12, 1e12),
+		newMockPool(base.Pancake, 4, coinA, coinB, 1e12, 1e12),
+	}
+	a, err := NewTradeAggregator(
+		contract.App{CoinList: contract.NewCustomCoinListApp([]types.CoinInfo{coinA, coinB, coinC})},
+		types.SimulationKeys{},
+		[]base.TradingPoolProvider{&mockProvider{pools: pools}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	all, err := a.GetQuotes(big.NewInt(1e8), coinA, coinB, RouteOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pruned, err := a.GetQuotes(big.NewInt(1e8), coinA, coinB, RouteOptions{TopK: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 4 || len(pruned) != 2 {
+		t.Fatalf("GetQuotes() = %d routes, pruned %d routes, want 4, 2", len(all), len(pruned))
+	}
+	if ((*big.Int)(all[0].Quote.OutputAmount)).Cmp(pruned[0].Quote.OutputAmount) != 0 {
+		t.Errorf("GetQuotes() best = %v, pruned best %v", all[0].Quote.OutputAmount, pruned[0].Quote.OutputAmount)
+	}
+}
+
+func TestTradeAggregator_SetLiquidityThreshold(t *testing.T) {
+	a := newMockAggregator()
+	a.SetLiquidityThreshold(LiquidityThreshold{MinReserve: big.NewInt(1e11)})
+	quotes, err := a.GetQuotes(big.NewInt(1e8), coinA, coinB, RouteOptions{MaxSteps: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(quotes) != 1 || quotes[0].Route.Steps[0].Pool.DexType() != base.Pancake {
+		t.Errorf("GetQuotes() = %d routes, want the pancake route only", len(quotes))
+	}
+
+	a.SetLiquidityThreshold(LiquidityThreshold{
+		MinReserve:      big.NewInt(1e11),
+		CoinMinReserves: map[string]*big.Int{coinA.TokenType.GetFullName(): big.NewInt(1e9), coinB.TokenType.GetFullName(): big.NewInt(1e9)},
+	})
+	quotes, err = a.GetQuotes(big.NewInt(1e8), coinA, coinB, RouteOptions{MaxSteps: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(quotes) != 2 {
+		t.Errorf("GetQuotes() = %d routes, want 2", len(quotes))
+	}
+}
+
+func TestTradeAggregator_QuoteCache(t *testing.T) {
+	a := newMockAggregator()
+	a.SetQuoteCache(NewQuoteCache(0, SignificantDigits(2)))
+	opts := RouteOptions{MaxSteps: 3}
+	for _, amount := range []int64{1e8, 1.01e8} {
+		if _, err := a.GetQuotes(big.NewInt(amount), coinA, coinB, opts); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := a.GetQuotes(big.NewInt(1e8), coinB, coinD, opts); err != nil {
+		t.Fatal(err)
+	}
+	if stats := a.QuoteCacheStats(); stats.Hits != 1 || stats.Misses != 2 || stats.Entries != 2 {
+		t.Fatalf("QuoteCacheStats() = %+v, want 1 hit, 2 misses, 2 entries", stats)
+	}
+
+	// reload with new B-D reserves, only B to D is invalidated
+	provider := a.poolProviders[0].(*mockProvider)
+	pools := append([]base.TradingPool{}, provider.pools[:4]...)
+	provider.pools = append(pools, newMockPool(base.Pancake, 4, coinB, coinD, 1e12, 2e12))
+	a.LoadAllPoolLists()
+	if stats := a.QuoteCacheStats(); stats.Invalidations != 1 || stats.Entries != 1 {
+		t.Fatalf("QuoteCacheStats() = %+v, want 1 invalidation, 1 entry", stats)
+	}
+	quotes, err := a.GetQuotes(big.NewInt(1e8), coinB, coinD, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out := (*big.Int)(quotes[0].Quote.OutputAmount); out.Cmp(big.NewInt(1.9e8)) < 0 {
+		t.Errorf("output = %s, want quote of new reserves", out)
+	}
+
+	// uncacheable options
+	if _, err := a.GetQuotes(big.NewInt(1e8), coinA, coinB, RouteOptions{PoolFilters: []PoolFilter{IncludeDexes(base.Aux)}}); err != nil {
+		t.Fatal(err)
+	}
+	if stats := a.QuoteCacheStats(); stats.Hits != 1 || stats.Misses != 3 {
+		t.Errorf("QuoteCacheStats() = %+v, want 1 hit, 3 misses", stats)
+	}
+}
+
+func TestTradeAggregator_QuoteCache_Copy(t *testing.T) {
+	a := newMockAggregator()
+	a.SetQuoteCache(NewQuoteCache(0, SignificantDigits(2)))
+	first, err := a.GetQuotes(big.NewInt(1e8), coinA, coinB, RouteOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := new(big.Int).Set(first[0].Quote.OutputAmount)
+	// callers own the returned quotes
+	first[0].Quote.OutputAmount = big.NewInt(0)
+
+	same, err := a.GetQuotes(big.NewInt(1e8), coinA, coinB, RouteOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if same[0] == first[0] || same[0].Quote == first[0].Quote {
+		t.Errorf("GetQuotes() return the cached quote")
+	}
+	if out := (*big.Int)(same[0].Quote.OutputAmount); out.Cmp(want) != 0 {
+		t.Errorf("cached quote output = %s, want %s", out, want)
+	}
+
+	// another amount of the bucket is quoted again on the cached routes, not scaled
+	other, err := a.GetQuotes(big.NewInt(1.09e8), coinA, coinB, RouteOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats := a.QuoteCacheStats(); stats.Hits != 2 {
+		t.Fatalf("QuoteCacheStats() = %+v, want 2 hits", stats)
+	}
+	uncached, err := newMockAggregator().GetQuotes(big.NewInt(1.09e8), coinA, coinB, RouteOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(other) != len(uncached) {
+		t.Fatalf("GetQuotes() = %d quotes, want %d", len(other), len(uncached))
+	}
+	for i := range other {
+		if in := (*big.Int)(other[i].Quote.InputAmount); in.Cmp(big.NewInt(1.09e8)) != 0 {
+			t.Errorf("cached route input = %s, want 109000000", in)
+		}
+		if out := (*big.Int)(other[i].Quote.OutputAmount); out.Cmp(uncached[i].Quote.OutputAmount) != 0 {
+			t.Errorf("cached route output = %s, want %v", out, uncached[i].Quote.OutputAmount)
+		}
+	}
+}
+
+func TestTradeAggregator_QuoteCache_TopK(t *testing.T) {
+	pools := []base.TradingPool{
+		newMockPool(base.Pancake, 0, coinA, coinC, 1e12, 1e12),
+		newMockPool(base.Aux, 1, coinA, coinC, 1e12, 2e12),
+		newMockPool(base.AnimeSwap, 2, coinA, coinC, 1e12, 0.5e12),
+		newMockPool(base.Pontem, 3, coinC, coinB, 1e12, 1e12),
+	}
+	a := newMockAggregatorWithPools([]types.CoinInfo{coinA, coinB, coinC}, pools)
+	a.SetQuoteCache(NewQuoteCache(0, nil))
+	opts := RouteOptions{TopK: 1}
+	quotes, err := a.GetQuotes(big.NewInt(1e8), coinA, coinB, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(quotes) != 1 || quotes[0].Route.Steps[0].Pool.DexType() != base.Aux {
+		t.Fatalf("GetQuotes() = %d routes, want the aux route", len(quotes))
+	}
+
+	// the pruned anime pool becomes the best
+	provider := a.poolProviders[0].(*mockProvider)
+	provider.pools = []base.TradingPool{pools[0], pools[1], newMockPool(base.AnimeSwap, 2, coinA, coinC, 1e12, 4e12), pools[3]}
+	a.LoadAllPoolLists()
+	if stats := a.QuoteCacheStats(); stats.Entries != 0 {
+		t.Fatalf("QuoteCacheStats() = %+v, want no entry", stats)
+	}
+	quotes, err = a.GetQuotes(big.NewInt(1e8), coinA, coinB, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if quotes[0].Route.Steps[0].Pool.DexType() != base.AnimeSwap {
+		t.Errorf("GetQuotes() best = %v, want the anime route", quotes[0].Route.Steps[0].Pool.DexType())
+	}
+}
+
+func TestTradeAggregator_QuoteCache_LiquidityThreshold(t *testing.T) {
+	a := newMockAggregator()
+	a.SetLiquidityThreshold(LiquidityThreshold{MinReserve: big.NewInt(1e11)})
+	a.SetQuoteCache(NewQuoteCache(0, nil))
+	opts := RouteOptions{MaxSteps: 1}
+	if _, err := a.GetQuotes(big.NewInt(1e8), coinA, coinB, opts); err != nil {
+		t.Fatal(err)
+	}
+
+	// the aux dust pool grows past the threshold
+	provider := a.poolProviders[0].(*mockProvider)
+	pools := append([]base.TradingPool{}, provider.pools...)
+	pools[1] = newMockPool(base.Aux, 1, coinB, coinA, 1e12, 1e12)
+	provider.pools = pools
+	a.LoadAllPoolLists()
+	quotes, err := a.GetQuotes(big.NewInt(1e8), coinA, coinB, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(quotes) != 2 {
+		t.Errorf("GetQuotes() = %d routes, want 2", len(quotes))
+	}
+}
+
+func TestTradeAggregator_RouteCache(t *testing.T) {
+	a := newMockAggregator()
+	opts := RouteOptions{MaxSteps: 3}
+	if _, err := a.GetAllRoutes(coinA, coinB, opts); err != nil {
+		t.Fatal(err)
+	}
+
+	// same topology, cached routes use the reloaded pool
+	provider := a.poolProviders[0].(*mockProvider)
+	reloaded := newMockPool(base.Pancake, 0, coinA, coinB, 1e12, 3e12)
+	provider.pools = append([]base.TradingPool{reloaded}, provider.pools[1:]...)
+	a.LoadAllPoolLists()
+	if len(a.routeCache.routes) != 1 {
+		t.Fatalf("cached pairs = %d, want 1", len(a.routeCache.routes))
+	}
+	routes, err := a.GetAllRoutes(coinA, coinB, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, route := range routes {
+		for _, step := range route.Steps {
+			if step.Pool.PoolId() == reloaded.PoolId() {
+				found = true
+				if step.Pool != base.TradingPool(reloaded) {
+					t.Errorf("route uses stale pool")
+				}
+			}
+		}
+	}
+	if !found {
+		t.Errorf("routes do not use pool %v", reloaded.PoolId())
+	}
+
+	// new pool changes topology
+	provider.pools = append(provider.pools, newMockPool(base.Aux, 5, coinC, coinD, 1e12, 1e12))
+	a.LoadAllPoolLists()
+	if len(a.routeCache.routes) != 0 {
+		t.Errorf("cached pairs = %d, want 0", len(a.routeCache.routes))
+	}
+
+	// routes searched before a reload are not cached
+	key, _ := routeCacheKey(coinA, coinB, opts)
+	_, generation, _ := a.routeCache.get(key)
+	a.LoadAllPoolLists()
+	a.routeCache.put(key, generation, routes)
+	if _, _, ok := a.routeCache.get(key); ok {
+		t.Errorf("routes of an older generation are cached")
+	}
+}
+
+func TestTradeAggregator_FindArbitrage(t *testing.T) {
+	// A is 2 B on pancake but 1 B on aux
+	a := newMockAggregatorWithPools([]types.CoinInfo{coinA, coinB, coinC}, []base.TradingPool{
+		newMockPool(base.Pancake, 0, coinA, coinB, 1e12, 2e12),
+		newMockPool(base.Aux, 1, coinB, coinA, 1e12, 1e12),
+		newMockPool(base.AnimeSwap, 2, coinA, coinC, 1e12, 1e12),
+	})
+	if _, err := a.FindArbitrage(coinA, 1, nil); err == nil {
+		t.Errorf("FindArbitrage() with 1 step, want error")
+	}
+	opportunities, err := a.FindArbitrage(coinA, 3, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(opportunities) != 1 {
+		t.Fatalf("FindArbitrage() = %d opportunities, want 1", len(opportunities))
+	}
+	o := opportunities[0]
+	if len(o.Route.Steps) != 2 || o.Route.Steps[0].Pool.DexType() != base.Pancake {
+		t.Errorf("route = %v, want pancake then aux", o.Route.Tokens)
+	}
+	if o.Profit.Sign() <= 0 || o.Payload.Function.IsZero() {
+		t.Errorf("opportunity = %+v", o)
+	}
+	// profit is max around the found input
+	for _, delta := range []int64{-1e8, 1e8} {
+		in := big.NewInt(0).Add(o.InputAmount, big.NewInt(delta))
+		out := (*big.Int)(o.Route.GetQuote(in).OutputAmount)
+		if big.NewInt(0).Sub(out, in).Cmp(o.Profit) > 0 {
+			t.Errorf("input %s has more profit than %s", in, o.InputAmount)
+		}
+	}
+
+	tooMuch := big.NewInt(0).Add(o.Profit, big.NewInt(1))
+	if opportunities, err := a.FindArbitrage(coinA, 3, tooMuch); err != nil || len(opportunities) != 0 {
+		t.Errorf("FindArbitrage() with min profit = %d, %v, want none", len(opportunities), err)
+	}
+}
+
+// searchOnlyPool hide ConstantProductParams of pool, so sizing searches with GetQuote
+type searchOnlyPool struct {
+	base.TradingPool
+}
+
+func TestOptimizeInput(t *testing.T) {
+	pancake := newMockPool(base.Pancake, 0, coinA, coinB, 1e12, 2e12)
+	aux := newMockPool(base.Aux, 1, coinB, coinA, 1e12, 1e12)
+	cycle := base.NewTradeRoute([]base.TradeStep{
+		base.NewTradeStep(pancake, true),
+		base.NewTradeStep(aux, true),
+	})
+	searchCycle := base.NewTradeRoute([]base.TradeStep{
+		base.NewTradeStep(searchOnlyPool{pancake}, true),
+		base.NewTradeStep(searchOnlyPool{aux}, true),
+	})
+	swap := base.NewTradeRoute([]base.TradeStep{base.NewTradeStep(pancake, true)})
+	searchSwap := base.NewTradeRoute([]base.TradeStep{base.NewTradeStep(searchOnlyPool{pancake}, true)})
+
+	// within 0.1% of each other
+	near := func(x, y *big.Int) bool {
+		diff := big.NewInt(0).Sub(x, y)
+		return big.NewInt(0).Mul(diff.Abs(diff), big.NewInt(1000)).Cmp(y) <= 0
+	}
+	tests := []struct {
+		name   string
+		closed base.TradeRoute
+		search base.TradeRoute
+		target SizingTarget
+	}{
+		{"max profit", cycle, searchCycle, MaxProfit()},
+		{"max impact", swap, searchSwap, MaxInputWithinImpact(100)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			closed, err := OptimizeInput(tt.closed, tt.target, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			search, err := OptimizeInput(tt.search, tt.target, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !closed.ClosedForm || search.ClosedForm {
+				t.Errorf("ClosedForm = %v, %v, want true, false", closed.ClosedForm, search.ClosedForm)
+			}
+			if closed.InputAmount.Sign() <= 0 || !near(closed.InputAmount, search.InputAmount) {
+				t.Errorf("InputAmount = %s closed form, %s searched", closed.InputAmount, search.InputAmount)
+			}
+		})
+	}
+
+	// 1% impact of x*y=k is about 1% of the reserve
+	sizing, _ := OptimizeInput(swap, MaxInputWithinImpact(100), nil)
+	if !near(sizing.InputAmount, big.NewInt(1.0131e10)) {
+		t.Errorf("InputAmount = %s, want about 1.0131e10", sizing.InputAmount)
+	}
+	if _, err := OptimizeInput(swap, MaxProfit(), nil); err == nil {
+		t.Errorf("OptimizeInput() max profit of A to B, want error")
+	}
+}
+
+func TestTradeAggregator_GetDepth(t *testing.T) {
+	a := newMockAggregator()
+	levels := []uint64{50, 100}
+	depth, err := a.GetDepth(coinA, coinB, levels)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(depth.Pools) != 2 || depth.BestRoute == nil {
+		t.Fatalf("GetDepth() = %d pools, best route %v, want 2 pools and best route", len(depth.Pools), depth.BestRoute)
+	}
+	for i, level := range levels {
+		sum := big.NewInt(0)
+		for _, pool := range depth.Pools {
+			p := pool.Points[i]
+			if p.InputAmount.Sign() <= 0 || p.SlippageBps > level {
+				t.Errorf("%s depth at %d bps = %+v", pool.Route.Steps[0].Pool.DexType().Name(), level, p)
+			}
+			sum.Add(sum, p.InputAmount)
+		}
+		if c := depth.Combined[i]; c.InputAmount.Cmp(sum) != 0 || c.SlippageBps > level {
+			t.Errorf("combined depth at %d bps = %+v, want input %s", level, c, sum)
+		}
+	}
+	if depth.Combined[0].InputAmount.Cmp(depth.Combined[1].InputAmount) >= 0 {
+		t.Errorf("depth at 50 bps %s, not less than at 100 bps %s", depth.Combined[0].InputAmount, depth.Combined[1].InputAmount)
+	}
+	if _, err = a.GetDepth(coinA, coinA, levels); err == nil {
+		t.Error("GetDepth() of a coin against itself, want error")
+	}
+}
+
+func TestPriceOracle(t *testing.T) {
+	// A is 2 B everywhere but on the shallow anime pool
+	a := newMockAggregatorWithPools([]types.CoinInfo{coinA, coinB, coinC}, []base.TradingPool{
+		newMockPool(base.Pancake, 0, coinA, coinB, 1e12, 2e12),
+		newMockPool(base.Aux, 1, coinB, coinA, 1e10, 5e9),
+		newMockPool(base.AnimeSwap, 2, coinA, coinB, 1e8, 1e9),
+		newMockPool(base.AnimeSwap, 3, coinA, coinC, 1e12, 1e12),
+		newMockPool(base.Pontem, 4, coinC, coinB, 1e12, 2e12),
+	})
+	oracle := NewPriceOracle(a, coinB)
+	oracle.FullConfidenceDepth = decimal.NewFromInt(100)
+	price, err := oracle.GetPrice(coinA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if price.Price.Sub(decimal.NewFromInt(2)).Abs().GreaterThan(decimal.New(1, -3)) {
+		t.Errorf("Price = %s, want 2", price.Price)
+	}
+	if price.Sources != 3 || price.Outliers != 1 {
+		t.Errorf("Sources, Outliers = %d, %d, want 3, 1", price.Sources, price.Outliers)
+	}
+	if price.Confidence < 0.99 {
+		t.Errorf("Confidence = %f, want about 1", price.Confidence)
+	}
+
+	tvl, err := oracle.PoolTVL(a.Pools()[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tvl.Sub(decimal.NewFromInt(40000)).Abs().GreaterThan(decimal.NewFromInt(1)) {
+		t.Errorf("PoolTVL() = %s, want 40000", tvl)
+	}
+
+	// the oracle values pools for the liquidity threshold
+	a.SetLiquidityThreshold(LiquidityThreshold{MinValue: decimal.NewFromInt(100), Valuer: oracle})
+	routes, err := a.GetAllRoutes(coinA, coinB, RouteOptions{MaxSteps: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(routes) != 2 {
+		t.Errorf("GetAllRoutes() = %d routes, want 2 without the shallow pool", len(routes))
+	}
+}
+
+// probedPool hides the constant product params of the pool
+type probedPool struct {
+	base.TradingPool
+}
+
+func TestStepMidPrice(t *testing.T) {
+	// a small pool, probing reserve/1e6 of it quotes 0
+	pool := newMockPool(base.AnimeSwap, 0, coinA, coinB, 1e9, 3)
+	for _, tc := range []struct {
+		step base.TradeStep
+		want *big.Rat
+	}{
+		{base.NewTradeStep(pool, true), big.NewRat(3, 1e9)},
+		{base.NewTradeStep(pool, false), big.NewRat(1e9, 3)},
+	} {
+		mid, ok := stepMidPrice(tc.step)
+		if !ok || mid.Cmp(tc.want) != 0 {
+			t.Errorf("stepMidPrice(isXtoY %v) = %v, %v, want %v", tc.step.IsXtoY, mid, ok, tc.want)
+		}
+	}
+
+	// pools without constant product params are probed
+	mid, ok := stepMidPrice(base.NewTradeStep(probedPool{newMockPool(base.Aux, 1, coinA, coinB, 1e12, 2e12)}, true))
+	if !ok {
+		t.Fatal("stepMidPrice() of a probed pool failed")
+	}
+	if f, _ := mid.Float64(); f < 1.999 || f > 2.001 {
+		t.Errorf("stepMidPrice() of a probed pool = %f, want 2", f)
+	}
+}
+
+func TestTradeAggregator_LiquidityThreshold_ValuedReload(t *testing.T) {
+	provider := &mockProvider{pools: []base.TradingPool{
+		newMockPool(base.Pancake, 0, coinA, coinC, 1e12, 1e12),
+		newMockPool(base.Pancake, 1, coinC, coinB, 1e12, 1e12),
+	}}
+	a, err := NewTradeAggregator(
+		contract.App{CoinList: contract.NewCustomCoinListApp([]types.CoinInfo{coinA, coinB, coinC})},
+		types.SimulationKeys{},
+		[]base.TradingPoolProvider{provider},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.SetLiquidityThreshold(LiquidityThreshold{MinValue: decimal.NewFromInt(1000), Valuer: NewPriceOracle(a, coinA)})
+	routes, err := a.GetAllRoutes(coinA, coinB, RouteOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(routes) != 1 {
+		t.Fatalf("GetAllRoutes() = %d routes, want 1", len(routes))
+	}
+
+	// C is worth 1000 times less after the reload, so the C-B pool is under the threshold
+	// though the pools are the same
+	provider.pools = []base.TradingPool{
+		newMockPool(base.Pancake, 0, coinA, coinC, 1e12, 1e15),
+		newMockPool(base.Pancake, 1, coinC, coinB, 1e12, 1e12),
+	}
+	if err = a.LoadAllPoolLists(); err != nil {
+		t.Fatal(err)
+	}
+	if routes, err = a.GetAllRoutes(coinA, coinB, RouteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if len(routes) != 0 {
+		t.Errorf("GetAllRoutes() after prices changed = %d routes, want 0", len(routes))
+	}
+}
+
+func TestRouteOptions_AllowUnverified(t *testing.T) {
+	unverified := mockCoin("U")
+	unverified.Unverified = true
+	a := newMockAggregatorWithPools([]types.CoinInfo{coinA, coinB, unverified}, []base.TradingPool{
+		newMockPool(base.Pancake, 0, coinA, unverified, 1e12, 1e12),
+		newMockPool(base.Pancake, 1, unverified, coinB, 1e12, 1e12),
+	})
+	input := big.NewInt(1e6)
+	quotes, err := a.GetQuotes(input, coinA, coinB, RouteOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(quotes) != 0 {
+		t.Errorf("GetQuotes() through unverified coin = %d, want 0", len(quotes))
+	}
+	quotes, err = a.GetQuotes(input, coinA, coinB, RouteOptions{AllowUnverified: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(quotes) != 1 {
+		t.Errorf("GetQuotes() with AllowUnverified = %d, want 1", len(quotes))
+	}
+	// unverified coins may always be traded directly
+	quotes, err = a.GetQuotes(input, coinA, unverified, RouteOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(quotes) != 1 {
+		t.Errorf("GetQuotes() to unverified coin = %d, want 1", len(quotes))
+	}
+}
+
+func TestTradeAggregator_CoinListReload(t *testing.T) {
+	app := &contract.CustomCoinListApp{}
+	app.Append([]types.CoinInfo{coinA, coinB})
+	loaded := make(chan struct{}, 4)
+	a, err := NewTradeAggregator(
+		contract.App{CoinList: app},
+		types.SimulationKeys{},
+		[]base.TradingPoolProvider{&mockProvider{pools: []base.TradingPool{
+			newMockPool(base.Pancake, 0, coinA, coinC, 1e12, 1e12),
+			newMockPool(base.Pancake, 1, coinC, coinB, 1e12, 1e12),
+		}, loaded: loaded}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-loaded
+	routes, err := a.GetAllRoutes(coinA, coinB, RouteOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(routes) != 0 {
+		t.Fatalf("GetAllRoutes() before C is listed = %d, want 0", len(routes))
+	}
+	// cached routes are dropped when the coin list changes
+	app.Append([]types.CoinInfo{coinC})
+	if err := a.CoinListClient().Reload(); err != nil {
+		t.Fatal(err)
+	}
+	routes, err = a.GetAllRoutes(coinA, coinB, RouteOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(routes) != 1 {
+		t.Errorf("GetAllRoutes() after reload = %d, want 1", len(routes))
+	}
+	// pools are reloaded for the added coin
+	select {
+	case <-loaded:
+	case <-time.After(time.Second):
+		t.Fatal("pools not reloaded after a coin is added")
+	}
+
+	a.Close()
+	app.Append([]types.CoinInfo{coinD})
+	if err := a.CoinListClient().Reload(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-loaded:
+		t.Error("pools reloaded after Close")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestTradeAggregator_CoinListReload_Close(t *testing.T) {
+	app := &contract.CustomCoinListApp{}
+	app.Append([]types.CoinInfo{coinA, coinB})
+	// loads block until the test receives from loaded, then until wait is closed but the first load
+	loaded := make(chan struct{})
+	wait := make(chan struct{}, 1)
+	wait <- struct{}{}
+	created := make(chan *TradeAggregator)
+	go func() {
+		a, err := NewTradeAggregator(contract.App{CoinList: app}, types.SimulationKeys{}, []base.TradingPoolProvider{&mockProvider{loaded: loaded, wait: wait}})
+		if err != nil {
+			t.Error(err)
+		}
+		created <- a
+	}()
+	<-loaded
+	a := <-created
+	if a == nil {
+		t.FailNow()
+	}
+	select {
+	case <-loaded:
+		t.Fatal("pools loaded again without a coin list change")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// the reload of the second change waits for the running one, Close drops it
+	app.Append([]types.CoinInfo{coinC})
+	if err := a.CoinListClient().Reload(); err != nil {
+		t.Fatal(err)
+	}
+	<-loaded
+	app.Append([]types.CoinInfo{coinD})
+	if err := a.CoinListClient().Reload(); err != nil {
+		t.Fatal(err)
+	}
+	a.Close()
+	close(wait)
+	select {
+	case <-loaded:
+		t.Error("pending reload ran after Close")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestNewTradeAggregator_CoinListError(t *testing.T) {
+	_, err := NewTradeAggregator(
+		contract.App{CoinList: contract.NewFileCoinListApp("testdata/missing.json")},
+		types.SimulationKeys{},
+		nil,
+	)
+	if err == nil {
+		t.Error("NewTradeAggregator() with a missing coin list, want error")
+	}
+}
+
+func TestRouteAndQuote_String(t *testing.T) {
+	a := newMockAggregatorWithPools([]types.CoinInfo{coinA, coinB}, []base.TradingPool{
+		newMockPool(base.Pancake, 0, coinA, coinB, 1e12, 2e12),
+	})
+	best, err := a.GetBestQuote(coinA.Unit(), coinA, coinB, RouteOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := best.String(); got != "1 A → 1.99380121 B" {
+		t.Errorf("String() = %q", got)
+	}
+}
@@ -1,15 +1,18 @@
 package anime
 
 import (
+	"fmt"
 	"github.com/coming-chat/go-aptos/aptosclient"
 	"github.com/coming-chat/go-aptos/aptostypes"
 	"github.com/omnibtc/go-hippo-sdk/aggregator/base"
 	"github.com/omnibtc/go-hippo-sdk/aggregator/coinlist"
 	"github.com/omnibtc/go-hippo-sdk/types"
 	"math/big"
-	"strings"
 )
 
+// ModuleAddress is the address AnimeSwap modules are published at on mainnet
+const ModuleAddress = "0x16fe2df00ea7dde4a63409201f7f4e536bde7bb7335526a35d05111e68aa322c"
+
 type LiquidityPool struct {
 	CoinXReserve         types.Coin
 	CoinYReserve         types.Coin
@@ -20,58 +23,50 @@ type LiquidityPool struct {
 	Locked               bool
 }
 
-func NewLiquidityPool(resource aptostypes.AccountResource) *LiquidityPool {
-	data := resource.Data
-	coinXValue, b := big.NewInt(0).SetString(data["coin_x_reserve"].(map[string]interface{})["value"].(string), 10)
-	if !b {
-		return nil
-	}
-	coinXReserve := types.Coin{
-		Value: coinXValue,
-	}
-
-	coinYValue, b := big.NewInt(0).SetString(data["coin_y_reserve"].(map[string]interface{})["value"].(string), 10)
-	if !b {
-		return nil
-	}
-	coinYReserve := types.Coin{
-		Value: coinYValue,
-	}
-	if coinXValue.Cmp(big.NewInt(0)) == 0 || coinYValue.Cmp(big.NewInt(0)) == 0 {
-		return nil
-	}
+// liquidityPoolResource is the json layout of AnimeSwapPoolV1::LiquidityPool
+type liquidityPoolResource struct {
+	CoinXReserve         types.Coin `json:"coin_x_reserve"`
+	CoinYReserve         types.Coin `json:"coin_y_reserve"`
+	LastBlockTimestamp   types.Uint `json:"last_block_timestamp"`
+	LastPriceXCumulative types.Uint `json:"last_price_x_cumulative"`
+	LastPriceYCumulative types.Uint `json:"last_price_y_cumulative"`
+	KLast                types.Uint `json:"k_last"`
+	Locked               bool       `json:"locked"`
+}
 
-	kLast, b := big.NewInt(0).SetString(data["k_last"].(string), 10)
-	if !b {
-		return nil
-	}
-	lastBlockTimestamp, b := big.NewInt(0).SetString(data["last_block_timestamp"].(string), 10)
-	if !b {
-		return nil
-	}
+func (r liquidityPoolResource) Validate() error {
+	return types.RequireFields(map[string]types.Field{
+		"coin_x_reserve":          r.CoinXReserve,
+		"coin_y_reserve":          r.CoinYReserve,
+		"last_block_timestamp":    r.LastBlockTimestamp,
+		"last_price_x_cumulative": r.LastPriceXCumulative,
+		"last_price_y_cumulative": r.LastPriceYCumulative,
+		"k_last":                  r.KLast,
+	})
+}
 
-	lastPriceXCumulative, b := big.NewInt(0).SetString(data["last_price_x_cumulative"].(string), 10)
-	if !b {
-		return nil
+func NewLiquidityPool(resource aptostypes.AccountResource) (*LiquidityPool, error) {
+	var data liquidityPoolResource
+	if err := base.DecodeResource(resource, &data); err != nil {
+		return nil, err
 	}
-	lastPriceYCumulative, b := big.NewInt(0).SetString(data["last_price_y_cumulative"].(string), 10)
-	if !b {
-		return nil
+	if data.CoinXReserve.Value.Sign() == 0 || data.CoinYReserve.Value.Sign() == 0 {
+		return nil, base.ErrEmptyPool
 	}
-	locked := data["locked"].(bool)
 
 	return &LiquidityPool{
-		CoinXReserve:         coinXReserve,
-		CoinYReserve:         coinYReserve,
-		LastBlockTimestamp:   lastBlockTimestamp.Int64(),
-		LastPriceXCumulative: lastPriceXCumulative,
-		LastPriceYCumulative: lastPriceYCumulative,
-		Locked:               locked,
-		KLast:                kLast,
-	}
+		CoinXReserve:         data.CoinXReserve,
+		CoinYReserve:         data.CoinYReserve,
+		LastBlockTimestamp:   data.LastBlockTimestamp.BigInt().Int64(),
+		LastPriceXCumulative: data.LastPriceXCumulative.BigInt(),
+		LastPriceYCumulative: data.LastPriceYCumulative.BigInt(),
+		Locked:               data.Locked,
+		KLast:                data.KLast.BigInt(),
+	}, nil
 }
 
 type AnimeTradingPool struct {
+	poolId     base.PoolId
 	OwnerAddr  string
 	_xCoinInfo types.CoinInfo
 	_yCoinInfo types.CoinInfo
@@ -79,18 +74,23 @@ type AnimeTradingPool struct {
 	Pool       LiquidityPool
 }
 
-func NewAnimeTradingPool(owner string, xCoin, yCoin types.CoinInfo, tag types.StructTag, resource aptostypes.AccountResource) *AnimeTradingPool {
-	pool := NewLiquidityPool(resource)
-	if pool == nil {
-		return nil
+func NewAnimeTradingPool(owner string, xCoin, yCoin types.CoinInfo, tag types.StructTag, resource aptostypes.AccountResource) (*AnimeTradingPool, error) {
+	pool, err := NewLiquidityPool(resource)
+	if err != nil {
+		return nil, err
 	}
 	return &AnimeTradingPool{
+		poolId:     base.NewPoolId(base.AnimeSwap, owner, tag),
 		OwnerAddr:  owner,
 		_xCoinInfo: xCoin,
 		_yCoinInfo: yCoin,
 		Tag:        tag,
 		Pool:       *pool,
-	}
+	}, nil
+}
+
+func (a *AnimeTradingPool) PoolId() base.PoolId {
+	return a.poolId
 }
 
 func (a *AnimeTradingPool) DexType() base.DexType {
@@ -117,10 +117,26 @@ func (a *AnimeTradingPool) IsStateLoaded() bool {
 	return true
 }
 
+func (a *AnimeTradingPool) Reserves() (reserveX, reserveY *big.Int) {
+	return a.Pool.CoinXReserve.Value, a.Pool.CoinYReserve.Value
+}
+
 func (a *AnimeTradingPool) GetPrice() base.PriceType {
 	panic("not implemented")
 }
 
+func (a *AnimeTradingPool) ConstantProductParams(isXToY bool) (base.ConstantProductParams, bool) {
+	return base.NewConstantProductParams(a.Pool.CoinXReserve.Value, a.Pool.CoinYReserve.Value, isXToY, 30), true
+}
+
+func (a *AnimeTradingPool) CumulativePrices() base.CumulativePrices {
+	return base.CumulativePrices{
+		PriceXCumulative: a.Pool.LastPriceXCumulative,
+		PriceYCumulative: a.Pool.LastPriceYCumulative,
+		Timestamp:        uint64(a.Pool.LastBlockTimestamp),
+	}
+}
+
 func (a *AnimeTradingPool) GetQuote(inputAmount base.TokenAmount, isXToY bool) base.QuoteType {
 	if !a.IsStateLoaded() {
 		panic("anime pool not loaded")
@@ -168,71 +184,15 @@ func getAmountOut(amountIn, reserveIn, reserveOut, swapFee *big.Int) *big.Int {
 	return amountOut
 }
 
-type AnimePoolProvider struct {
-	client         *aptosclient.RestClient
-	ownerAddress   string
-	coinListClient *coinlist.CoinListClient
-	resourceTypes  []string
-}
-
 func NewPoolProvider(client *aptosclient.RestClient, ownerAddress string, coinListClient *coinlist.CoinListClient) base.TradingPoolProvider {
-	return &AnimePoolProvider{
-		client:         client,
-		ownerAddress:   ownerAddress,
-		coinListClient: coinListClient,
-	}
-}
-
-func (p *AnimePoolProvider) SetResourceTypes(resourceTypes []string) {
-	if len(resourceTypes) == 0 {
-		return
-	}
-	p.resourceTypes = resourceTypes
-}
-
-func (p *AnimePoolProvider) LoadPoolList() []base.TradingPool {
-	poolList := make([]base.TradingPool, 0)
-	resources, err := p.client.GetAccountResources(p.ownerAddress, 0)
-	if err != nil {
-		for _, resourceType := range p.resourceTypes {
-			resource, err := p.client.GetAccountResource(p.ownerAddress, resourceType, 0)
-			if err != nil {
-				continue
-			}
-			resources = append(resources, *resource)
-		}
-	}
-
-	for _, resource := range resources {
-		if !strings.Contains(resource.Type, "AnimeSwapPoolV1::LiquidityPool") {
-			continue
-		}
-		tag, err := types.ParseMoveStructTag(resource.Type)
-		if err != nil {
-			// todo handle error
-			continue
-		}
-		if len(tag.TypeParams) < 2 {
-			continue
-		}
-		xTag := tag.TypeParams[0].StructTag
-		yTag := tag.TypeParams[1].StructTag
-		if nil == xTag || nil == yTag {
-			continue
-		}
-		xCoinInfo, bx := p.coinListClient.GetCoinInfoByType(xTag)
-		yCoinInfo, by := p.coinListClient.GetCoinInfoByType(yTag)
-		if !bx || !by {
-			continue
-		}
-		pool := NewAnimeTradingPool(p.ownerAddress, xCoinInfo, yCoinInfo, tag, resource)
-		if pool == nil {
-			// todo handle error
-			continue
-		}
-
-		poolList = append(poolList, pool)
-	}
-
-	return poolList
+	return base.NewPoolProvider(client, ownerAddress, coinListClient, base.PoolSpec{
+		ResourceMatcher: base.ContainsMatcher("AnimeSwapPoolV1::LiquidityPool"),
+		CoinTypeParams:  [2]int{0, 1},
+		ResourceTypes: func(x, y string) []string {
+			return []string{fmt.Sprintf("%s::AnimeSwapPoolV1::LiquidityPool<%s, %s>", ModuleAddress, x, y)}
+		},
+		Decode: func(ctx base.PoolContext, resource aptostypes.AccountResource) (base.TradingPool, error) {
+			return NewAnimeTradingPool(ctx.OwnerAddress, ctx.XCoinInfo, ctx.YCoinInfo, ctx.Tag, resource)
+		},
+	})
 }
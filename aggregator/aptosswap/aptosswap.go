@@ -2,13 +2,13 @@ package aptosswap
 
 import (
 	"errors"
+	"fmt"
 	"github.com/coming-chat/go-aptos/aptosclient"
 	"github.com/coming-chat/go-aptos/aptostypes"
 	"github.com/omnibtc/go-hippo-sdk/aggregator/base"
 	"github.com/omnibtc/go-hippo-sdk/aggregator/coinlist"
 	"github.com/omnibtc/go-hippo-sdk/types"
 	"math/big"
-	"strings"
 )
 
 var (
@@ -66,6 +66,39 @@ func NewAptoswapPoolInfo(poolType AptoswapPoolType, typeString string, swapType
 	return pool
 }
 
+// poolResource is the json layout of aptoswap pool::Pool
+type poolResource struct {
+	PoolType     *uint8     `json:"pool_type"`
+	FeeDirection *uint8     `json:"fee_direction"`
+	Index        types.Uint `json:"index"`
+	X            types.Coin `json:"x"`
+	Y            types.Coin `json:"y"`
+	LspSupply    types.Uint `json:"lsp_supply"`
+	Freeze       bool       `json:"freeze"`
+	AdminFee     types.Uint `json:"admin_fee"`
+	LpFee        types.Uint `json:"lp_fee"`
+	IncentiveFee types.Uint `json:"incentive_fee"`
+	ConnectFee   types.Uint `json:"connect_fee"`
+	WithdrawFee  types.Uint `json:"withdraw_fee"`
+}
+
+func (r poolResource) Validate() error {
+	if r.PoolType == nil || r.FeeDirection == nil {
+		return errors.New("missing fields: pool_type or fee_direction")
+	}
+	return types.RequireFields(map[string]types.Field{
+		"index":         r.Index,
+		"x":             r.X,
+		"y":             r.Y,
+		"lsp_supply":    r.LspSupply,
+		"admin_fee":     r.AdminFee,
+		"lp_fee":        r.LpFee,
+		"incentive_fee": r.IncentiveFee,
+		"connect_fee":   r.ConnectFee,
+		"withdraw_fee":  r.WithdrawFee,
+	})
+}
+
 func MapResourceToPoolInfo(resource aptostypes.AccountResource) (*AptoswapPoolInfo, error) {
 	var swapType AptoswapSwapType
 	var feeDirection AptoswapFeeDirection
@@ -75,6 +108,9 @@ func MapResourceToPoolInfo(resource aptostypes.AccountResource) (*AptoswapPoolIn
 	if err != nil {
 		return nil, err
 	}
+	if len(tag.TypeParams) < 2 || tag.TypeParams[0].StructTag == nil || tag.TypeParams[1].StructTag == nil {
+		return nil, errors.New("invalid aptoswap pool type")
+	}
 	xCoinType := AptoswapCoinType{
 		Network: "aptos",
 		Name:    tag.TypeParams[0].StructTag.Name,
@@ -83,62 +119,30 @@ func MapResourceToPoolInfo(resource aptostypes.AccountResource) (*AptoswapPoolIn
 		Network: "aptos",
 		Name:    tag.TypeParams[1].StructTag.Name,
 	}
-	data := resource.Data
+	var data poolResource
+	if err = base.DecodeResource(resource, &data); err != nil {
+		return nil, err
+	}
 	poolType := AptoswapPoolType{
 		XTokenType: xCoinType,
 		YTokenType: yCoinType,
 	}
-	if data["pool_type"].(float64) == 100 {
+	if *data.PoolType == 100 {
 		swapType = "v2"
 	} else {
 		swapType = "stable"
 	}
-	if data["fee_direction"].(float64) == 200 {
+	if *data.FeeDirection == 200 {
 		feeDirection = "X"
 	} else {
 		feeDirection = "Y"
 	}
-	index, b := big.NewInt(0).SetString(data["index"].(string), 10)
-	if !b {
-		return nil, nil
-	}
-	x, b := big.NewInt(0).SetString(data["x"].(map[string]interface{})["value"].(string), 10)
-	if !b {
-		return nil, nil
-	}
-	y, b := big.NewInt(0).SetString(data["y"].(map[string]interface{})["value"].(string), 10)
-	if !b {
-		return nil, nil
-	}
-	if x.Cmp(big.NewInt(0)) == 0 || y.Cmp(big.NewInt(0)) == 0 {
-		return nil, nil
+	if data.X.Value.Sign() == 0 || data.Y.Value.Sign() == 0 {
+		return nil, base.ErrEmptyPool
 	}
-	lspSupply, b := big.NewInt(0).SetString(data["lsp_supply"].(string), 10)
-	if !b {
-		return nil, nil
-	}
-	freeze := data["freeze"].(bool)
-	adminFee, b := big.NewInt(0).SetString(data["admin_fee"].(string), 10)
-	if !b {
-		return nil, nil
-	}
-	lpFee, b := big.NewInt(0).SetString(data["lp_fee"].(string), 10)
-	if !b {
-		return nil, nil
-	}
-	incentiveFee, b := big.NewInt(0).SetString(data["incentive_fee"].(string), 10)
-	if !b {
-		return nil, nil
-	}
-	connectFee, b := big.NewInt(0).SetString(data["connect_fee"].(string), 10)
-	if !b {
-		return nil, nil
-	}
-	withdrawFee, b := big.NewInt(0).SetString(data["withdraw_fee"].(string), 10)
-	if !b {
-		return nil, nil
-	}
-	return NewAptoswapPoolInfo(poolType, typeString, swapType, feeDirection, freeze, index, x, y, lspSupply, adminFee, lpFee, incentiveFee, connectFee, withdrawFee), nil
+	return NewAptoswapPoolInfo(poolType, typeString, swapType, feeDirection, data.Freeze,
+		data.Index.BigInt(), data.X.Value, data.Y.Value, data.LspSupply.BigInt(),
+		data.AdminFee.BigInt(), data.LpFee.BigInt(), data.IncentiveFee.BigInt(), data.ConnectFee.BigInt(), data.WithdrawFee.BigInt()), nil
 }
 
 func (a *AptoswapPoolInfo) GetXToYAmount(dx *big.Int) *big.Int {
@@ -191,6 +195,7 @@ func (a *AptoswapPoolInfo) _computeAmount(dx, x, y *big.Int) *big.Int {
 }
 
 type AptoswapTradingPool struct {
+	poolId      base.PoolId
 	PackageAddr string
 	_xCoinInfo  types.CoinInfo
 	_yCoinInfo  types.CoinInfo
@@ -200,6 +205,7 @@ type AptoswapTradingPool struct {
 
 func NewAptoswapTradingPool(packageAddr string, _xCoinInfo, _yCoinInfo types.CoinInfo, tag types.StructTag, resource aptostypes.AccountResource) (*AptoswapTradingPool, error) {
 	aptoswapTradingPool := &AptoswapTradingPool{
+		poolId:      base.NewPoolId(base.Aptosswap, packageAddr, tag),
 		PackageAddr: packageAddr,
 		_xCoinInfo:  _xCoinInfo,
 		_yCoinInfo:  _yCoinInfo,
@@ -209,13 +215,14 @@ func NewAptoswapTradingPool(packageAddr string, _xCoinInfo, _yCoinInfo types.Coi
 	if err != nil {
 		return nil, err
 	}
-	if pool == nil {
-		return nil, errors.New("not found pool")
-	}
 	aptoswapTradingPool.Pool = pool
 	return aptoswapTradingPool, nil
 }
 
+func (a *AptoswapTradingPool) PoolId() base.PoolId {
+	return a.poolId
+}
+
 func (a *AptoswapTradingPool) DexType() base.DexType {
 	return base.Aptosswap
 }
@@ -240,10 +247,42 @@ func (a *AptoswapTradingPool) IsStateLoaded() bool {
 	return true
 }
 
+func (a *AptoswapTradingPool) Reserves() (reserveX, reserveY *big.Int) {
+	return a.Pool.X, a.Pool.Y
+}
+
 func (a *AptoswapTradingPool) GetPrice() base.PriceType {
 	panic("not implemented")
 }
 
+// ConstantProductParams return the swap params of v2 pools, the admin fee is charged from input or output by fee direction
+func (a *AptoswapTradingPool) ConstantProductParams(isXToY bool) (base.ConstantProductParams, bool) {
+	if a.Pool.SwapType != "v2" {
+		return base.ConstantProductParams{}, false
+	}
+	var inputDirection AptoswapFeeDirection = "X"
+	reserveIn, reserveOut := a.Pool.X, a.Pool.Y
+	if !isXToY {
+		inputDirection = "Y"
+		reserveIn, reserveOut = reserveOut, reserveIn
+	}
+	scaling := a.Pool.BpsScaling
+	adminFactor := new(big.Rat).SetFrac(new(big.Int).Sub(scaling, a.Pool.TotalAdminFee()), scaling)
+	inputFactor := new(big.Rat).SetFrac(new(big.Int).Sub(scaling, a.Pool.TotalLpFee()), scaling)
+	outputFactor := big.NewRat(1, 1)
+	if a.Pool.FeeDirection == inputDirection {
+		inputFactor.Mul(inputFactor, adminFactor)
+	} else {
+		outputFactor = adminFactor
+	}
+	return base.ConstantProductParams{
+		ReserveIn:    reserveIn,
+		ReserveOut:   reserveOut,
+		InputFactor:  inputFactor,
+		OutputFactor: outputFactor,
+	}, true
+}
+
 func (a *AptoswapTradingPool) GetQuote(inputAmount base.TokenAmount, isXToY bool) base.QuoteType {
 	if !a.IsStateLoaded() {
 		panic("aptosswap pool not loaded")
@@ -276,71 +315,15 @@ func (a *AptoswapTradingPool) MakePayload(input base.TokenAmount, minOut base.To
 	panic("not implemented")
 }
 
-type AptoswapPoolProvider struct {
-	client         *aptosclient.RestClient
-	ownerAddress   string
-	coinListClient *coinlist.CoinListClient
-	resourceTypes  []string
-}
-
 func NewPoolProvider(client *aptosclient.RestClient, ownerAddress string, coinListClient *coinlist.CoinListClient) base.TradingPoolProvider {
-	return &AptoswapPoolProvider{
-		client:         client,
-		ownerAddress:   ownerAddress,
-		coinListClient: coinListClient,
-	}
-}
-
-func (p *AptoswapPoolProvider) SetResourceTypes(resourceTypes []string) {
-	if len(resourceTypes) == 0 {
-		return
-	}
-	p.resourceTypes = resourceTypes
-}
-
-func (p *AptoswapPoolProvider) LoadPoolList() []base.TradingPool {
-	poolList := make([]base.TradingPool, 0)
-	resources, err := p.client.GetAccountResources(p.ownerAddress, 0)
-	if err != nil {
-		for _, resourceType := range p.resourceTypes {
-			resource, err := p.client.GetAccountResource(p.ownerAddress, resourceType, 0)
-			if err != nil {
-				continue
-			}
-			resources = append(resources, *resource)
-		}
-	}
-
-	for _, resource := range resources {
-		if !strings.Contains(resource.Type, "pool::Pool") {
-			continue
-		}
-		tag, err := types.ParseMoveStructTag(resource.Type)
-		if err != nil {
-			// todo handle error
-			continue
-		}
-		if len(tag.TypeParams) < 2 {
-			continue
-		}
-		xTag := tag.TypeParams[0].StructTag
-		yTag := tag.TypeParams[1].StructTag
-		if nil == xTag || nil == yTag {
-			continue
-		}
-		xCoinInfo, bx := p.coinListClient.GetCoinInfoByType(xTag)
-		yCoinInfo, by := p.coinListClient.GetCoinInfoByType(yTag)
-		if !bx || !by {
-			continue
-		}
-		pool, err := NewAptoswapTradingPool(p.ownerAddress, xCoinInfo, yCoinInfo, tag, resource)
-		if err != nil {
-			// todo handle error
-			continue
-		}
-
-		poolList = append(poolList, pool)
-	}
-
-	return poolList
+	return base.NewPoolProvider(client, ownerAddress, coinListClient, base.PoolSpec{
+		ResourceMatcher: base.ContainsMatcher("pool::Pool"),
+		CoinTypeParams:  [2]int{0, 1},
+		ResourceTypes: func(x, y string) []string {
+			return []string{fmt.Sprintf("%s::pool::Pool<%s, %s>", ownerAddress, x, y)}
+		},
+		Decode: func(ctx base.PoolContext, resource aptostypes.AccountResource) (base.TradingPool, error) {
+			return NewAptoswapTradingPool(ctx.OwnerAddress, ctx.XCoinInfo, ctx.YCoinInfo, ctx.Tag, resource)
+		},
+	})
 }
@@ -0,0 +1,125 @@
+package aggregator
+
+import (
+	"errors"
+	"math/big"
+	"sort"
+
+	"github.com/omnibtc/go-hippo-sdk/aggregator/base"
+	"github.com/omnibtc/go-hippo-sdk/types"
+)
+
+// ArbitrageOpportunity is a profitable cycle which starts and ends at the same coin
+type ArbitrageOpportunity struct {
+	Route        base.TradeRoute
+	InputAmount  *big.Int
+	OutputAmount *big.Int
+	// Profit is OutputAmount minus InputAmount
+	Profit *big.Int
+	// Payload swap InputAmount along Route, it fails unless the output is at least InputAmount plus minProfit
+	Payload types.EntryFunctionPayload
+}
+
+// FindArbitrage find cycles of 2 to maxSteps routable pools from startCoin back to startCoin,
+// the input amount of each cycle is chosen to maximize profit, cycles with profit under minProfit are dropped.
+// Result is sorted by profit, most first.
+func (a *TradeAggregator) FindArbitrage(startCoin types.CoinInfo, maxSteps int, minProfit *big.Int) ([]*ArbitrageOpportunity, error) {
+	if maxSteps < 2 {
+		return nil, errors.New("arbitrage cycle needs at least 2 steps")
+	}
+	if maxSteps > MaxRouteSteps {
+		maxSteps = MaxRouteSteps
+	}
+	if minProfit == nil || minProfit.Sign() <= 0 {
+		minProfit = big.NewInt(1)
+	}
+
+	cycles, err := a.getCycles(startCoin, maxSteps)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*ArbitrageOpportunity, 0)
+	for _, cycle := range cycles {
+		sizing, err := OptimizeInput(cycle, MaxProfit(), nil)
+		if err != nil {
+			continue
+		}
+		input, output := sizing.InputAmount, (*big.Int)(sizing.OutputAmount)
+		profit := big.NewInt(0).Sub(output, input)
+		if profit.Cmp(minProfit) < 0 {
+			continue
+		}
+		result = append(result, &ArbitrageOpportunity{
+			Route:        cycle,
+			InputAmount:  input,
+			OutputAmount: output,
+			Profit:       profit,
+			Payload:      cycle.MakePayload(input, big.NewInt(0).Add(input, minProfit)),
+		})
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Profit.Cmp(result[j].Profit) > 0
+	})
+	return result, nil
+}
+
+// getCycles return routes from start back to start which pass no other coin twice and use no pool twice
+func (a *TradeAggregator) getCycles(start types.CoinInfo, maxSteps int) ([]base.TradeRoute, error) {
+	fullList, err := a.coinList()
+	if err != nil {
+		return nil, err
+	}
+	// cycles never pass unverified coins
+	fullList = RouteOptions{}.filterIntermediates(fullList)
+	var filters []PoolFilter
+	if liquidityFilter := a.getLiquidityFilter(); liquidityFilter != nil {
+		filters = append(filters, liquidityFilter)
+	}
+	startFullName := start.TokenType.GetFullName()
+	result := make([]base.TradeRoute, 0)
+	for _, k := range fullList {
+		if k.TokenType.GetFullName() == startFullName {
+			continue
+		}
+		firstSteps := a.getDirectSteps(start, k, true, filters)
+		if len(firstSteps) == 0 {
+			continue
+		}
+		backRoutes := a.getDirectSteps(k, start, true, filters)
+		returns := make([]base.TradeRoute, 0, len(backRoutes))
+		for _, step := range backRoutes {
+			returns = append(returns, base.NewTradeRoute([]base.TradeStep{step}))
+		}
+		if maxSteps >= 3 {
+			returns = append(returns, a.getTwoStepRoutes(k, start, fullList, filters)...)
+		}
+		for _, first := range firstSteps {
+			for _, back := range returns {
+				cycle := base.NewTradeRoute(appendSteps([]base.TradeStep{first}, back.Steps))
+				if reusesPool(cycle) {
+					continue
+				}
+				result = append(result, cycle)
+			}
+		}
+	}
+	return result, nil
+}
+
+func reusesPool(route base.TradeRoute) bool {
+	s := make(map[base.PoolId]struct{}, len(route.Steps))
+	for _, step := range route.Steps {
+		id := step.Pool.PoolId()
+		if _, ok := s[id]; ok {
+			return true
+		}
+		s[id] = struct{}{}
+	}
+	return false
+}
+
+func appendSteps(steps []base.TradeStep, more []base.TradeStep) []base.TradeStep {
+	result := make([]base.TradeStep, 0, len(steps)+len(more))
+	result = append(result, steps...)
+	return append(result, more...)
+}
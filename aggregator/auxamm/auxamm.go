@@ -3,10 +3,9 @@ package auxamm
 import (
 	"fmt"
 	"math/big"
-	"strconv"
-	"strings"
 
 	"github.com/coming-chat/go-aptos/aptosclient"
+	"github.com/coming-chat/go-aptos/aptostypes"
 	"github.com/omnibtc/go-hippo-sdk/aggregator/base"
 	"github.com/omnibtc/go-hippo-sdk/aggregator/coinlist"
 	"github.com/omnibtc/go-hippo-sdk/types"
@@ -14,20 +13,25 @@ import (
 )
 
 type TradingPool struct {
-	xCoinInfo     types.CoinInfo
-	yCoinInfo     types.CoinInfo
-	feeBps        int
-	frozen        bool
-	coinXReserve  *big.Int
-	coinYReserve  *big.Int
-	ownerAddress  string
-	scriptAddress string
+	poolId       base.PoolId
+	xCoinInfo    types.CoinInfo
+	yCoinInfo    types.CoinInfo
+	feeBps       int
+	frozen       bool
+	coinXReserve *big.Int
+	coinYReserve *big.Int
+	ownerAddress string
+	swapFunction types.FunctionId
 }
 
 func NewTradingPool() base.TradingPool {
 	return &TradingPool{}
 }
 
+func (t *TradingPool) PoolId() base.PoolId {
+	return t.poolId
+}
+
 func (t *TradingPool) DexType() base.DexType {
 	return base.Aux
 }
@@ -52,11 +56,19 @@ func (t *TradingPool) IsStateLoaded() bool {
 	return t.coinXReserve != nil && t.coinYReserve != nil
 }
 
+func (t *TradingPool) Reserves() (reserveX, reserveY *big.Int) {
+	return t.coinXReserve, t.coinYReserve
+}
+
 // ReloadState() error
 func (t *TradingPool) GetPrice() base.PriceType {
 	panic("not implemented")
 }
 
+func (t *TradingPool) ConstantProductParams(isXToY bool) (base.ConstantProductParams, bool) {
+	return base.NewConstantProductParams(t.coinXReserve, t.coinYReserve, isXToY, int64(t.feeBps)), true
+}
+
 func (t *TradingPool) GetQuote(inputAmount base.TokenAmount, isXToY bool) base.QuoteType {
 	if !t.IsStateLoaded() {
 		panic("aux pool not loaded")
@@ -95,7 +107,7 @@ func (t *TradingPool) MakePayload(input base.TokenAmount, minOut base.TokenAmoun
 	typeArgs := make([]string, 0)
 	typeArgs = append(typeArgs, xTokenType.GetFullName(), yTokenType.GetFullName())
 	return types.EntryFunctionPayload{
-		Function: fmt.Sprintf("%s::%s::%s", t.scriptAddress, "amm", "swap_exact_coin_for_coin_with_signer"),
+		Function: t.swapFunction,
 		TypeArgs: typeArgs,
 		Args: []interface{}{
 			inputAmount,
@@ -104,93 +116,53 @@ func (t *TradingPool) MakePayload(input base.TokenAmount, minOut base.TokenAmoun
 	}
 }
 
-type AuxPoolProvider struct {
-	client         *aptosclient.RestClient
-	ownerAddress   string
-	coinListClient *coinlist.CoinListClient
-	resourceTypes  []string
-	scriptAddress  string
+// poolResource is the json layout of aux amm::Pool
+type poolResource struct {
+	XReserve types.Coin `json:"x_reserve"`
+	YReserve types.Coin `json:"y_reserve"`
+	FeeBps   types.Uint `json:"fee_bps"`
+	Frozen   bool       `json:"frozen"`
 }
 
-func NewPoolProvider(client *aptosclient.RestClient, ownerAddress string, coinListClient *coinlist.CoinListClient, scriptAddress string) base.TradingPoolProvider {
-	return &AuxPoolProvider{
-		client:         client,
-		ownerAddress:   ownerAddress,
-		coinListClient: coinListClient,
-		scriptAddress:  scriptAddress,
-	}
+func (r poolResource) Validate() error {
+	return types.RequireFields(map[string]types.Field{
+		"x_reserve": r.XReserve,
+		"y_reserve": r.YReserve,
+		"fee_bps":   r.FeeBps,
+	})
 }
 
-func (p *AuxPoolProvider) SetResourceTypes(resourceTypes []string) {
-	if len(resourceTypes) == 0 {
-		return
-	}
-	p.resourceTypes = resourceTypes
-}
-
-func (p *AuxPoolProvider) LoadPoolList() []base.TradingPool {
-	poolList := make([]base.TradingPool, 0)
-	resources, err := p.client.GetAccountResources(p.ownerAddress, 0)
+func NewPoolProvider(client *aptosclient.RestClient, ownerAddress string, coinListClient *coinlist.CoinListClient, scriptAddress string) (base.TradingPoolProvider, error) {
+	swapFunction, err := types.NewFunctionId(scriptAddress, "amm", "swap_exact_coin_for_coin_with_signer")
 	if err != nil {
-		for _, resourceType := range p.resourceTypes {
-			resource, err := p.client.GetAccountResource(p.ownerAddress, resourceType, 0)
-			if err != nil {
-				continue
-			}
-			resources = append(resources, *resource)
-		}
-	}
-
-	for _, resource := range resources {
-		if !strings.Contains(resource.Type, "amm::Pool") {
-			continue
-		}
-		tag, err := types.ParseMoveStructTag(resource.Type)
-		if err != nil {
-			// todo handle error
-			continue
-		}
-		if len(tag.TypeParams) < 2 {
-			continue
-		}
-		xTag := tag.TypeParams[0].StructTag
-		yTag := tag.TypeParams[1].StructTag
-		if nil == xTag || nil == yTag {
-			continue
-		}
-		xCoinInfo, bx := p.coinListClient.GetCoinInfoByType(xTag)
-		yCoinInfo, by := p.coinListClient.GetCoinInfoByType(yTag)
-		if !bx || !by {
-			continue
-		}
-
-		x := resource.Data["x_reserve"].(map[string]interface{})["value"].(string)
-		y := resource.Data["y_reserve"].(map[string]interface{})["value"].(string)
-		if x == "0" || y == "0" {
-			continue
-		}
-		xint, b := big.NewInt(0).SetString(x, 10)
-		if !b {
-			continue
-		}
-		yint, b := big.NewInt(0).SetString(y, 10)
-		if !b {
-			continue
-		}
-		feeBps, _ := strconv.Atoi(resource.Data["fee_bps"].(string))
-		frozen := resource.Data["frozen"].(bool)
-
-		poolList = append(poolList, &TradingPool{
-			xCoinInfo:     xCoinInfo,
-			yCoinInfo:     yCoinInfo,
-			ownerAddress:  p.ownerAddress,
-			coinXReserve:  xint,
-			coinYReserve:  yint,
-			feeBps:        feeBps,
-			frozen:        frozen,
-			scriptAddress: p.scriptAddress,
-		})
+		return nil, err
 	}
+	return base.NewPoolProvider(client, ownerAddress, coinListClient, base.PoolSpec{
+		ResourceMatcher: base.ContainsMatcher("amm::Pool"),
+		CoinTypeParams:  [2]int{0, 1},
+		ResourceTypes: func(x, y string) []string {
+			return []string{fmt.Sprintf("%s::amm::Pool<%s, %s>", ownerAddress, x, y)}
+		},
+		Decode: func(ctx base.PoolContext, resource aptostypes.AccountResource) (base.TradingPool, error) {
+			var data poolResource
+			if err := base.DecodeResource(resource, &data); err != nil {
+				return nil, err
+			}
+			if data.XReserve.Value.Sign() == 0 || data.YReserve.Value.Sign() == 0 {
+				return nil, base.ErrEmptyPool
+			}
 
-	return poolList
+			return &TradingPool{
+				poolId:       base.NewPoolId(base.Aux, ctx.OwnerAddress, ctx.Tag),
+				xCoinInfo:    ctx.XCoinInfo,
+				yCoinInfo:    ctx.YCoinInfo,
+				ownerAddress: ctx.OwnerAddress,
+				coinXReserve: data.XReserve.Value,
+				coinYReserve: data.YReserve.Value,
+				feeBps:       int(data.FeeBps.BigInt().Int64()),
+				frozen:       data.Frozen,
+				swapFunction: swapFunction,
+			}, nil
+		},
+	}), nil
 }
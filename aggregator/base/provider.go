@@ -0,0 +1,291 @@
+package base
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/coming-chat/go-aptos/aptosclient"
+	"github.com/coming-chat/go-aptos/aptostypes"
+	"github.com/omnibtc/go-hippo-sdk/aggregator/coinlist"
+	"github.com/omnibtc/go-hippo-sdk/types"
+)
+
+// PoolContext is what the provider already knows about a pool resource before decoding it
+type PoolContext struct {
+	OwnerAddress string
+	Tag          types.StructTag
+	XCoinInfo    types.CoinInfo
+	YCoinInfo    types.CoinInfo
+}
+
+// PoolSpec describe how to find and decode the pools of a DEX
+type PoolSpec struct {
+	// ResourceMatcher report whether an account resource type is a pool of the DEX
+	ResourceMatcher func(resourceType string) bool
+	// CoinTypeParams is the index of X and Y coin in the type params of pool struct
+	CoinTypeParams [2]int
+	// ResourceTypes format the candidate pool resource types of X and Y full name, eg. one per curve,
+	// they are used to guess pool resources when account resources can not be listed
+	ResourceTypes func(x, y string) []string
+	// Decode build the trading pool from a matched resource
+	Decode func(ctx PoolContext, resource aptostypes.AccountResource) (TradingPool, error)
+}
+
+// ContainsMatcher match resource types which contain substr
+func ContainsMatcher(substr string) func(resourceType string) bool {
+	return func(resourceType string) bool {
+		return strings.Contains(resourceType, substr)
+	}
+}
+
+// ErrSkipPool is the error of pools which are valid but not loaded, eg. empty pools,
+// they are skipped without a PoolError, Decode may wrap it
+var ErrSkipPool = errors.New("pool skipped")
+
+var (
+	// ErrUnknownCoin is the error of pools whose coins are neither in the coin list nor discovered
+	ErrUnknownCoin = fmt.Errorf("coin not in coin list: %w", ErrSkipPool)
+	// ErrEmptyPool is the error of pools without reserves
+	ErrEmptyPool = fmt.Errorf("empty pool: %w", ErrSkipPool)
+)
+
+// PoolError is an account resource matched as a pool which could not be loaded
+type PoolError struct {
+	ResourceType string
+	Err          error
+}
+
+func (e *PoolError) Error() string {
+	return fmt.Sprintf("load pool %s: %v", e.ResourceType, e.Err)
+}
+
+func (e *PoolError) Unwrap() error {
+	return e.Err
+}
+
+// PoolErrors is every resource a load failed on, the pools of other resources are still loaded
+type PoolErrors []*PoolError
+
+func (e PoolErrors) Error() string {
+	if len(e) == 1 {
+		return e[0].Error()
+	}
+	return fmt.Sprintf("%v (and %d more)", e[0], len(e)-1)
+}
+
+// ListError is a load which could not list every resource of owner,
+// the pools of the pages read, or of guessed resource types if no page was read, are still loaded
+type ListError struct {
+	Owner string
+	Err   error
+	// PoolErrors is the resources read which could not be loaded
+	PoolErrors PoolErrors
+}
+
+func (e *ListError) Error() string {
+	if len(e.PoolErrors) > 0 {
+		return fmt.Sprintf("list resources of %s: %v, %v", e.Owner, e.Err, e.PoolErrors)
+	}
+	return fmt.Sprintf("list resources of %s: %v", e.Owner, e.Err)
+}
+
+func (e *ListError) Unwrap() error {
+	return e.Err
+}
+
+// ResourceLoader list the account resources pools are parsed from
+type ResourceLoader interface {
+	// PageAccountResources call handle with every page of the resources of owner
+	PageAccountResources(owner string, pageSize int, handle func(resources []aptostypes.AccountResource)) error
+	// GetAccountResourcesByTypes fetch the resource types of owner which exist
+	GetAccountResourcesByTypes(owner string, resourceTypes []string, parallelism int) []aptostypes.AccountResource
+}
+
+// restResourceLoader load resources from a node
+type restResourceLoader struct {
+	client *aptosclient.RestClient
+}
+
+func (l restResourceLoader) PageAccountResources(owner string, pageSize int, handle func(resources []aptostypes.AccountResource)) error {
+	return PageAccountResources(l.client, owner, pageSize, handle)
+}
+
+func (l restResourceLoader) GetAccountResourcesByTypes(owner string, resourceTypes []string, parallelism int) []aptostypes.AccountResource {
+	return GetAccountResourcesByTypes(l.client, owner, resourceTypes, parallelism)
+}
+
+// StaticResourceLoader serve a fixed list of resources for any owner, eg. resources recorded from a node
+type StaticResourceLoader []aptostypes.AccountResource
+
+func (l StaticResourceLoader) PageAccountResources(owner string, pageSize int, handle func(resources []aptostypes.AccountResource)) error {
+	handle(l)
+	return nil
+}
+
+func (l StaticResourceLoader) GetAccountResourcesByTypes(owner string, resourceTypes []string, parallelism int) []aptostypes.AccountResource {
+	wanted := make(map[string]bool, len(resourceTypes))
+	for _, t := range resourceTypes {
+		wanted[t] = true
+	}
+	resources := make([]aptostypes.AccountResource, 0)
+	for _, r := range l {
+		if wanted[r.Type] {
+			resources = append(resources, r)
+		}
+	}
+	return resources
+}
+
+// PoolProvider is a TradingPoolProvider that loads the pools held by an owner account according to a PoolSpec
+type PoolProvider struct {
+	loader         ResourceLoader
+	ownerAddress   string
+	coinListClient *coinlist.CoinListClient
+	resourceTypes  []string
+	spec           PoolSpec
+}
+
+func NewPoolProvider(client *aptosclient.RestClient, ownerAddress string, coinListClient *coinlist.CoinListClient, spec PoolSpec) *PoolProvider {
+	return &PoolProvider{
+		loader:         restResourceLoader{client: client},
+		ownerAddress:   ownerAddress,
+		coinListClient: coinListClient,
+		spec:           spec,
+	}
+}
+
+// SetResourceLoader load resources with loader instead of the node client
+func (p *PoolProvider) SetResourceLoader(loader ResourceLoader) {
+	p.loader = loader
+}
+
+func (p *PoolProvider) SetResourceTypes(resourceTypes []string) {
+	if len(resourceTypes) == 0 {
+		return
+	}
+	p.resourceTypes = resourceTypes
+}
+
+// getResourceTypes return the resource types set by SetResourceTypes,
+// or candidate pool types of every coin pair in coin list if not set
+func (p *PoolProvider) getResourceTypes() []string {
+	if len(p.resourceTypes) > 0 {
+		return p.resourceTypes
+	}
+	if p.spec.ResourceTypes == nil {
+		return nil
+	}
+	return PairResourceTypes(p.coinListClient.GetCoinInfoList(), p.spec.ResourceTypes)
+}
+
+func (p *PoolProvider) LoadPoolList() []TradingPool {
+	poolList, _ := p.LoadPools()
+	return poolList
+}
+
+// LoadPools load the pools of owner, each page of resources is parsed as it is read.
+// The error is PoolErrors of the matched resources which are not loaded, or a *ListError if paging failed.
+func (p *PoolProvider) LoadPools() ([]TradingPool, error) {
+	poolList := make([]TradingPool, 0)
+	poolErrors := make(PoolErrors, 0)
+	pages := 0
+	err := p.loader.PageAccountResources(p.ownerAddress, DefaultResourcePageSize, func(page []aptostypes.AccountResource) {
+		pages++
+		pools, errs := p.parsePoolList(page)
+		poolList = append(poolList, pools...)
+		poolErrors = append(poolErrors, errs...)
+	})
+	if err != nil {
+		// the account can not be listed at all, eg. too many resources, guess the pool resource types instead
+		if pages == 0 {
+			resources := p.loader.GetAccountResourcesByTypes(p.ownerAddress, p.getResourceTypes(), DefaultResourceParallelism)
+			poolList, poolErrors = p.parsePoolList(resources)
+		}
+		return poolList, &ListError{Owner: p.ownerAddress, Err: err, PoolErrors: poolErrors}
+	}
+	if len(poolErrors) > 0 {
+		return poolList, poolErrors
+	}
+	return poolList, nil
+}
+
+// poolResource is a matched resource with its parsed type and coin types
+type poolResource struct {
+	resource aptostypes.AccountResource
+	tag      types.StructTag
+	xTag     *types.StructTag
+	yTag     *types.StructTag
+}
+
+// parsePoolList parse the pools from a page of account resources,
+// coins missing in the list are discovered in one batch before the pools are decoded
+func (p *PoolProvider) parsePoolList(resources []aptostypes.AccountResource) ([]TradingPool, PoolErrors) {
+	poolList := make([]TradingPool, 0)
+	poolErrors := make(PoolErrors, 0)
+	matched := make([]poolResource, 0)
+	unknown := make([]types.TokenType, 0)
+	for _, resource := range resources {
+		if !p.spec.ResourceMatcher(resource.Type) {
+			continue
+		}
+		parsed, err := p.parsePoolResource(resource)
+		if err != nil {
+			poolErrors = append(poolErrors, &PoolError{ResourceType: resource.Type, Err: err})
+			continue
+		}
+		for _, coinTag := range []*types.StructTag{parsed.xTag, parsed.yTag} {
+			if !p.coinListClient.HasTokenType(coinTag) {
+				unknown = append(unknown, coinTag)
+			}
+		}
+		matched = append(matched, parsed)
+	}
+	if len(unknown) > 0 {
+		p.coinListClient.DiscoverCoinInfos(unknown)
+	}
+
+	for _, parsed := range matched {
+		pool, err := p.decodePool(parsed)
+		if errors.Is(err, ErrSkipPool) {
+			continue
+		}
+		if err != nil {
+			poolErrors = append(poolErrors, &PoolError{ResourceType: parsed.resource.Type, Err: err})
+			continue
+		}
+		poolList = append(poolList, pool)
+	}
+	return poolList, poolErrors
+}
+
+func (p *PoolProvider) parsePoolResource(resource aptostypes.AccountResource) (poolResource, error) {
+	tag, err := types.ParseMoveStructTag(resource.Type)
+	if err != nil {
+		return poolResource{}, err
+	}
+	xIdx, yIdx := p.spec.CoinTypeParams[0], p.spec.CoinTypeParams[1]
+	if len(tag.TypeParams) <= xIdx || len(tag.TypeParams) <= yIdx {
+		return poolResource{}, errors.New("missing coin type params")
+	}
+	xTag := tag.TypeParams[xIdx].StructTag
+	yTag := tag.TypeParams[yIdx].StructTag
+	if nil == xTag || nil == yTag {
+		return poolResource{}, errors.New("coin type param is not struct")
+	}
+	return poolResource{resource: resource, tag: tag, xTag: xTag, yTag: yTag}, nil
+}
+
+func (p *PoolProvider) decodePool(parsed poolResource) (TradingPool, error) {
+	xCoinInfo, bx := p.coinListClient.GetCoinInfoByType(parsed.xTag)
+	yCoinInfo, by := p.coinListClient.GetCoinInfoByType(parsed.yTag)
+	if !bx || !by {
+		return nil, ErrUnknownCoin
+	}
+	return p.spec.Decode(PoolContext{
+		OwnerAddress: p.ownerAddress,
+		Tag:          parsed.tag,
+		XCoinInfo:    xCoinInfo,
+		YCoinInfo:    yCoinInfo,
+	}, parsed.resource)
+}
@@ -0,0 +1,219 @@
+package base
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/coming-chat/go-aptos/aptostypes"
+	"github.com/omnibtc/go-hippo-sdk/aggregator/coinlist"
+	"github.com/omnibtc/go-hippo-sdk/contract"
+	"github.com/omnibtc/go-hippo-sdk/types"
+)
+
+// fakeLoader serve one page per resource, if pageErr is set paging fails with it after pages pages
+type fakeLoader struct {
+	resources []aptostypes.AccountResource
+	pageErr   error
+	pages     int
+	requested []string
+}
+
+func (l *fakeLoader) PageAccountResources(owner string, pageSize int, handle func(resources []aptostypes.AccountResource)) error {
+	for i, resource := range l.resources {
+		if l.pageErr != nil && i >= l.pages {
+			return l.pageErr
+		}
+		handle([]aptostypes.AccountResource{resource})
+	}
+	return l.pageErr
+}
+
+func (l *fakeLoader) GetAccountResourcesByTypes(owner string, resourceTypes []string, parallelism int) []aptostypes.AccountResource {
+	l.requested = resourceTypes
+	return StaticResourceLoader(l.resources).GetAccountResourcesByTypes(owner, resourceTypes, parallelism)
+}
+
+type fakePool struct {
+	TradingPool
+	ctx PoolContext
+}
+
+func TestPoolProvider_LoadPools(t *testing.T) {
+	coins := make([]types.CoinInfo, 0)
+	for _, symbol := range []string{"A", "B"} {
+		tag, err := types.ParseMoveStructTag(fmt.Sprintf("0x1::%s::%s", symbol, symbol))
+		if err != nil {
+			t.Fatal(err)
+		}
+		coins = append(coins, types.CoinInfo{Symbol: symbol, TokenType: &tag})
+	}
+	coinListClient, err := coinlist.LoadCoinListClient(contract.App{CoinList: contract.NewCustomCoinListApp(coins)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	errDecode := errors.New("bad reserves")
+	spec := func(coinTypeParams [2]int) PoolSpec {
+		return PoolSpec{
+			ResourceMatcher: ContainsMatcher("::pool::Pool"),
+			CoinTypeParams:  coinTypeParams,
+			ResourceTypes: func(x, y string) []string {
+				return []string{fmt.Sprintf("0x2::pool::Pool<%s, %s>", x, y)}
+			},
+			Decode: func(ctx PoolContext, resource aptostypes.AccountResource) (TradingPool, error) {
+				if resource.Data["broken"] != nil {
+					return nil, errDecode
+				}
+				if resource.Data["empty"] != nil {
+					return nil, ErrEmptyPool
+				}
+				return &fakePool{ctx: ctx}, nil
+			},
+		}
+	}
+	resource := func(resourceType string, data map[string]interface{}) aptostypes.AccountResource {
+		return aptostypes.AccountResource{Type: resourceType, Data: data}
+	}
+
+	tests := []struct {
+		name           string
+		coinTypeParams [2]int
+		loader         *fakeLoader
+		wantPools      int
+		wantX          string
+		wantErrors     []error
+		wantRequested  int
+		wantListErr    bool
+	}{
+		{
+			name:           "matcher skip other resources",
+			coinTypeParams: [2]int{0, 1},
+			loader: &fakeLoader{resources: []aptostypes.AccountResource{
+				resource("0x2::pool::Pool<0x1::A::A, 0x1::B::B>", nil),
+				resource("0x2::pool::LPCoin<0x1::A::A, 0x1::B::B>", nil),
+				resource("0x1::coin::CoinStore<0x1::A::A>", nil),
+			}},
+			wantPools: 1,
+			wantX:     "A",
+		},
+		{
+			name:           "coin type params",
+			coinTypeParams: [2]int{2, 1},
+			loader: &fakeLoader{resources: []aptostypes.AccountResource{
+				resource("0x2::pool::Pool<0x2::curves::Stable, 0x1::A::A, 0x1::B::B>", nil),
+			}},
+			wantPools: 1,
+			wantX:     "B",
+		},
+		{
+			name:           "missing coin type params",
+			coinTypeParams: [2]int{0, 2},
+			loader: &fakeLoader{resources: []aptostypes.AccountResource{
+				resource("0x2::pool::Pool<0x1::A::A, 0x1::B::B>", nil),
+			}},
+			wantErrors: []error{nil},
+		},
+		{
+			name:           "skip unknown coin",
+			coinTypeParams: [2]int{0, 1},
+			loader: &fakeLoader{resources: []aptostypes.AccountResource{
+				resource("0x2::pool::Pool<0x1::A::A, 0x1::C::C>", nil),
+				resource("0x2::pool::Pool<0x1::A::A, 0x1::B::B>", nil),
+			}},
+			wantPools: 1,
+			wantX:     "A",
+		},
+		{
+			name:           "skip empty pool",
+			coinTypeParams: [2]int{0, 1},
+			loader: &fakeLoader{resources: []aptostypes.AccountResource{
+				resource("0x2::pool::Pool<0x1::A::A, 0x1::B::B>", map[string]interface{}{"empty": true}),
+				resource("0x2::pool::Pool<0x1::B::B, 0x1::A::A>", nil),
+			}},
+			wantPools: 1,
+			wantX:     "B",
+		},
+		{
+			name:           "decode error",
+			coinTypeParams: [2]int{0, 1},
+			loader: &fakeLoader{resources: []aptostypes.AccountResource{
+				resource("0x2::pool::Pool<0x1::A::A, 0x1::B::B>", map[string]interface{}{"broken": true}),
+				resource("0x2::pool::Pool<0x1::B::B, 0x1::A::A>", nil),
+			}},
+			wantPools:  1,
+			wantX:      "B",
+			wantErrors: []error{errDecode},
+		},
+		{
+			name:           "fetch candidate types if resources can not be listed",
+			coinTypeParams: [2]int{0, 1},
+			loader: &fakeLoader{pageErr: errors.New("too many resources"), resources: []aptostypes.AccountResource{
+				resource("0x2::pool::Pool<0x1::A::A, 0x1::B::B>", nil),
+			}},
+			wantPools:     1,
+			wantX:         "A",
+			wantRequested: 2,
+			wantListErr:   true,
+		},
+		{
+			name:           "keep pages read before paging fails",
+			coinTypeParams: [2]int{0, 1},
+			loader: &fakeLoader{pageErr: errors.New("connection reset"), pages: 1, resources: []aptostypes.AccountResource{
+				resource("0x2::pool::Pool<0x1::A::A, 0x1::B::B>", nil),
+			}},
+			wantPools:   1,
+			wantX:       "A",
+			wantListErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewPoolProvider(nil, "0x2", coinListClient, spec(tt.coinTypeParams))
+			p.SetResourceLoader(tt.loader)
+			pools, err := p.LoadPools()
+			if len(pools) != tt.wantPools {
+				t.Fatalf("LoadPools() pools = %d, want %d", len(pools), tt.wantPools)
+			}
+			if tt.wantPools > 0 && pools[0].(*fakePool).ctx.XCoinInfo.Symbol != tt.wantX {
+				t.Errorf("LoadPools() x = %s, want %s", pools[0].(*fakePool).ctx.XCoinInfo.Symbol, tt.wantX)
+			}
+			if len(tt.loader.requested) != tt.wantRequested {
+				t.Errorf("LoadPools() requested %d resource types, want %d", len(tt.loader.requested), tt.wantRequested)
+			}
+			var listErr *ListError
+			if errors.As(err, &listErr) != tt.wantListErr {
+				t.Errorf("LoadPools() error = %v, want list error %t", err, tt.wantListErr)
+			}
+			if len(tt.wantErrors) == 0 {
+				if err != nil && !tt.wantListErr {
+					t.Errorf("LoadPools() error = %v", err)
+				}
+				return
+			}
+			var poolErrors PoolErrors
+			if !errors.As(err, &poolErrors) || len(poolErrors) != len(tt.wantErrors) {
+				t.Fatalf("LoadPools() error = %v, want %d pool errors", err, len(tt.wantErrors))
+			}
+			for i, want := range tt.wantErrors {
+				if want != nil && !errors.Is(poolErrors[i], want) {
+					t.Errorf("LoadPools() error[%d] = %v, want %v", i, poolErrors[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestNewPoolId(t *testing.T) {
+	tag, err := types.ParseMoveStructTag("0x2::pool::Pool<0x1::A::A, 0x1::B::B>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lower := NewPoolId(Pancake, "0xc7efb4076dbe143cbcd98cfaaa929ecfc8f299203dfff63b95ccb6bfe19850fa", tag)
+	upper := NewPoolId(Pancake, "0xC7EFB4076DBE143CBCD98CFAAA929ECFC8F299203DFFF63B95CCB6BFE19850FA", tag)
+	if lower != upper {
+		t.Errorf("NewPoolId() = %v and %v, want the same id", lower, upper)
+	}
+	if id := NewPoolId(Pancake, "0x0000000000000000000000000000000000000000000000000000000000000002", tag); id.OwnerAddress != "0x2" {
+		t.Errorf("NewPoolId() owner = %s, want 0x2", id.OwnerAddress)
+	}
+}
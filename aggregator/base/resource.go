@@ -0,0 +1,157 @@
+package base
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/coming-chat/go-aptos/aptosclient"
+	"github.com/coming-chat/go-aptos/aptostypes"
+	"github.com/omnibtc/go-hippo-sdk/types"
+)
+
+// DefaultResourceParallelism is the max number of concurrent GetAccountResource requests
+const DefaultResourceParallelism = 8
+
+// PairResourceTypes build candidate resource types for every ordered (X, Y) pair of coins,
+// format receives the full name of X and Y and returns the candidate resource types of the pool
+func PairResourceTypes(coins []types.CoinInfo, format func(x, y string) []string) []string {
+	resourceTypes := make([]string, 0, len(coins)*len(coins))
+	for _, x := range coins {
+		xFullName := x.TokenType.GetFullName()
+		for _, y := range coins {
+			yFullName := y.TokenType.GetFullName()
+			if xFullName == yFullName {
+				continue
+			}
+			resourceTypes = append(resourceTypes, format(xFullName, yFullName)...)
+		}
+	}
+	return resourceTypes
+}
+
+// GetAccountResourcesByTypes fetch every resource type of owner with at most parallelism requests in flight,
+// resource types which can not be fetched (usually not exist) are skipped, result keeps the order of resourceTypes
+func GetAccountResourcesByTypes(client *aptosclient.RestClient, owner string, resourceTypes []string, parallelism int) []aptostypes.AccountResource {
+	if parallelism <= 0 {
+		parallelism = DefaultResourceParallelism
+	}
+	fetched := make([]*aptostypes.AccountResource, len(resourceTypes))
+	sem := make(chan struct{}, parallelism)
+	wg := sync.WaitGroup{}
+	for i, resourceType := range resourceTypes {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, resourceType string) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			resource, err := client.GetAccountResource(owner, resourceType, 0)
+			if err != nil {
+				return
+			}
+			fetched[i] = resource
+		}(i, resourceType)
+	}
+	wg.Wait()
+
+	resources := make([]aptostypes.AccountResource, 0)
+	for _, resource := range fetched {
+		if resource == nil {
+			continue
+		}
+		resources = append(resources, *resource)
+	}
+	return resources
+}
+
+// ResourceValidator is implemented by resource layouts which check the decoded data, eg. for missing fields
+type ResourceValidator interface {
+	Validate() error
+}
+
+// DecodeResource unmarshal the data of resource into v, which is usually a struct with json tags,
+// v is validated if it is a ResourceValidator
+func DecodeResource(resource aptostypes.AccountResource, v interface{}) error {
+	data, err := json.Marshal(resource.Data)
+	if err != nil {
+		return err
+	}
+	if err = json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("decode resource %s: %w", resource.Type, err)
+	}
+	if validator, ok := v.(ResourceValidator); ok {
+		if err = validator.Validate(); err != nil {
+			return fmt.Errorf("decode resource %s: %w", resource.Type, err)
+		}
+	}
+	return nil
+}
+
+// DefaultResourcePageSize is the number of resources requested per page when paging account resources
+const DefaultResourcePageSize = 1000
+
+// ResourceHttpClient is the http client used to page account resources
+var ResourceHttpClient = &http.Client{
+	Timeout: 30 * time.Second,
+}
+
+// PageAccountResources page through all resources of owner with the cursor returned by node,
+// handle is called with every page in order so the caller never holds the whole account in memory
+func PageAccountResources(client *aptosclient.RestClient, owner string, pageSize int, handle func(resources []aptostypes.AccountResource)) error {
+	if pageSize <= 0 {
+		pageSize = DefaultResourcePageSize
+	}
+	cursor := ""
+	for {
+		resources, next, err := getAccountResourcesPage(client, owner, cursor, pageSize)
+		if err != nil {
+			return err
+		}
+		handle(resources)
+		if next == "" {
+			return nil
+		}
+		cursor = next
+	}
+}
+
+// getAccountResourcesPage return a page of resources and the cursor of next page, cursor is empty on last page
+func getAccountResourcesPage(client *aptosclient.RestClient, owner, cursor string, limit int) ([]aptostypes.AccountResource, string, error) {
+	req, err := http.NewRequest("GET", client.GetVersionedRpcUrl()+"/accounts/"+owner+"/resources", nil)
+	if err != nil {
+		return nil, "", err
+	}
+	q := req.URL.Query()
+	q.Add("limit", strconv.Itoa(limit))
+	if cursor != "" {
+		q.Add("start", cursor)
+	}
+	req.URL.RawQuery = q.Encode()
+
+	resp, err := ResourceHttpClient.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", err
+	}
+	if resp.StatusCode >= 400 {
+		restError := &aptostypes.RestError{}
+		json.Unmarshal(body, restError)
+		restError.Code = resp.StatusCode
+		return nil, "", restError
+	}
+	resources := make([]aptostypes.AccountResource, 0)
+	if err = json.Unmarshal(body, &resources); err != nil {
+		return nil, "", err
+	}
+	return resources, resp.Header.Get("X-Aptos-Cursor"), nil
+}
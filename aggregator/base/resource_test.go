@@ -0,0 +1,149 @@
+package base
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/coming-chat/go-aptos/aptosclient"
+	"github.com/coming-chat/go-aptos/aptostypes"
+	"github.com/omnibtc/go-hippo-sdk/types"
+)
+
+func TestPageAccountResources(t *testing.T) {
+	const total = 25
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1" {
+			w.Write([]byte(`{"chain_id":1,"ledger_version":"1","ledger_timestamp":"1","block_height":"1"}`))
+			return
+		}
+		start, _ := strconv.Atoi(r.URL.Query().Get("start"))
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		page := make([]aptostypes.AccountResource, 0)
+		for i := start; i < start+limit && i < total; i++ {
+			page = append(page, aptostypes.AccountResource{Type: fmt.Sprintf("0x1::m::R%d", i)})
+		}
+		if start+limit < total {
+			w.Header().Set("X-Aptos-Cursor", strconv.Itoa(start+limit))
+		}
+		json.NewEncoder(w).Encode(page)
+	}))
+	defer server.Close()
+
+	client, err := aptosclient.Dial(context.Background(), server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pages := 0
+	types := make([]string, 0)
+	err = PageAccountResources(client, "0x1", 10, func(resources []aptostypes.AccountResource) {
+		pages++
+		for _, r := range resources {
+			types = append(types, r.Type)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pages != 3 || len(types) != total {
+		t.Errorf("PageAccountResources() pages = %d, resources = %d, want 3, %d", pages, len(types), total)
+	}
+	if types[total-1] != fmt.Sprintf("0x1::m::R%d", total-1) {
+		t.Errorf("PageAccountResources() last = %s", types[total-1])
+	}
+}
+
+func TestPairResourceTypes(t *testing.T) {
+	coins := make([]types.CoinInfo, 0)
+	for _, name := range []string{"0x1::a::A", "0x1::b::B", "0x1::c::C"} {
+		tag, err := types.ParseMoveStructTag(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		coins = append(coins, types.CoinInfo{TokenType: &tag})
+	}
+	resourceTypes := PairResourceTypes(coins, func(x, y string) []string {
+		return []string{
+			fmt.Sprintf("0x2::pool::Pool<%s, %s, 0x2::curves::Uncorrelated>", x, y),
+			fmt.Sprintf("0x2::pool::Pool<%s, %s, 0x2::curves::Stable>", x, y),
+		}
+	})
+	if len(resourceTypes) != 3*2*2 {
+		t.Fatalf("PairResourceTypes() len = %d, want %d", len(resourceTypes), 3*2*2)
+	}
+	if resourceTypes[0] != "0x2::pool::Pool<0x1::a::A, 0x1::b::B, 0x2::curves::Uncorrelated>" ||
+		resourceTypes[1] != "0x2::pool::Pool<0x1::a::A, 0x1::b::B, 0x2::curves::Stable>" {
+		t.Errorf("PairResourceTypes() = %v", resourceTypes[:2])
+	}
+}
+
+func TestGetAccountResourcesByTypes(t *testing.T) {
+	const parallelism = 3
+	var inFlight, maxInFlight int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1" {
+			w.Write([]byte(`{"chain_id":1,"ledger_version":"1","ledger_timestamp":"1","block_height":"1"}`))
+			return
+		}
+		n := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			max := atomic.LoadInt32(&maxInFlight)
+			if n <= max || atomic.CompareAndSwapInt32(&maxInFlight, max, n) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+
+		resourceType := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+		switch {
+		case strings.HasSuffix(resourceType, "Missing"):
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error_code":"resource_not_found","message":"resource not found"}`))
+		case strings.HasSuffix(resourceType, "Broken"):
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`{"error_code":"internal_error","message":"internal error"}`))
+		default:
+			json.NewEncoder(w).Encode(aptostypes.AccountResource{Type: resourceType, Data: map[string]interface{}{}})
+		}
+	}))
+	defer server.Close()
+
+	client, err := aptosclient.Dial(context.Background(), server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resourceTypes := make([]string, 0)
+	want := make([]string, 0)
+	for i := 0; i < 12; i++ {
+		switch i % 4 {
+		case 1:
+			resourceTypes = append(resourceTypes, fmt.Sprintf("0x1::m::R%dMissing", i))
+		case 3:
+			resourceTypes = append(resourceTypes, fmt.Sprintf("0x1::m::R%dBroken", i))
+		default:
+			resourceTypes = append(resourceTypes, fmt.Sprintf("0x1::m::R%d", i))
+			want = append(want, fmt.Sprintf("0x1::m::R%d", i))
+		}
+	}
+
+	resources := GetAccountResourcesByTypes(client, "0x1", resourceTypes, parallelism)
+	if len(resources) != len(want) {
+		t.Fatalf("GetAccountResourcesByTypes() len = %d, want %d", len(resources), len(want))
+	}
+	for i, resource := range resources {
+		if resource.Type != want[i] {
+			t.Errorf("GetAccountResourcesByTypes()[%d] = %s, want %s", i, resource.Type, want[i])
+		}
+	}
+	if max := atomic.LoadInt32(&maxInFlight); max > parallelism {
+		t.Errorf("GetAccountResourcesByTypes() %d requests in flight, want at most %d", max, parallelism)
+	}
+}
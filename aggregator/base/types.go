@@ -27,6 +27,26 @@ const (
 
 type PoolType uint64
 
+// PoolId identify a pool by its DEX, the account holding the pool resource and the resource type,
+// the owner address is normalized so the same pool has one id however the address is written
+type PoolId struct {
+	Dex          DexType
+	OwnerAddress string
+	ResourceType string
+}
+
+func NewPoolId(dex DexType, ownerAddress string, tag types.StructTag) PoolId {
+	return PoolId{
+		Dex:          dex,
+		OwnerAddress: types.NormalizeAddress(ownerAddress),
+		ResourceType: tag.GetFullName(),
+	}
+}
+
+func (id PoolId) String() string {
+	return fmt.Sprintf("%s@%s::%s", id.Dex.Name(), id.OwnerAddress, id.ResourceType)
+}
+
 type TokenAmount *big.Int
 type TokenAmountRatio *big.Int
 
@@ -42,13 +62,21 @@ type QuoteType struct {
 	OutputAmount TokenAmount
 }
 
+// Format the quote in whole coins, eg. 1.5 APT → 12.34 USDC
+func (q QuoteType) Format(input, output types.CoinInfo) string {
+	return fmt.Sprintf("%s → %s", input.FormatAmount(q.InputAmount), output.FormatAmount(q.OutputAmount))
+}
+
 type TradingPool interface {
+	PoolId() PoolId
 	DexType() DexType
 	PoolType() PoolType
 	IsRoutable() bool
 	XCoinInfo() types.CoinInfo
 	YCoinInfo() types.CoinInfo
 	IsStateLoaded() bool
+	// Reserves return the amount of X and Y held by pool
+	Reserves() (reserveX, reserveY *big.Int)
 	// ReloadState() error
 	GetPrice() PriceType
 	GetQuote(inputAmount TokenAmount, isXToY bool) QuoteType
@@ -56,11 +84,66 @@ type TradingPool interface {
 	MakePayload(input TokenAmount, minOut TokenAmount, isXToY bool) types.EntryFunctionPayload
 }
 
+// ConstantProductParams describe a swap of a constant product pool,
+// output = OutputFactor * ReserveOut * in' / (ReserveIn + in') where in' = InputFactor * input
+type ConstantProductParams struct {
+	ReserveIn    *big.Int
+	ReserveOut   *big.Int
+	InputFactor  *big.Rat
+	OutputFactor *big.Rat
+}
+
+// NewConstantProductParams return the params of a pool charging feeBps of input
+func NewConstantProductParams(reserveX, reserveY *big.Int, isXToY bool, feeBps int64) ConstantProductParams {
+	if !isXToY {
+		reserveX, reserveY = reserveY, reserveX
+	}
+	return ConstantProductParams{
+		ReserveIn:    reserveX,
+		ReserveOut:   reserveY,
+		InputFactor:  big.NewRat(10000-feeBps, 10000),
+		OutputFactor: big.NewRat(1, 1),
+	}
+}
+
+// ConstantProductPool is implemented by pools which swap by x*y=k, which have closed form input sizing
+type ConstantProductPool interface {
+	// ConstantProductParams return the params of swap in direction isXToY, false if the pool does not swap by x*y=k
+	ConstantProductParams(isXToY bool) (ConstantProductParams, bool)
+}
+
+// CumulativePrices is the on-chain price accumulators of a pool, in UQ64x64 fixed point price times seconds,
+// which wrap around at 2^128
+type CumulativePrices struct {
+	// PriceXCumulative sum the price of X in Y
+	PriceXCumulative *big.Int
+	// PriceYCumulative sum the price of Y in X
+	PriceYCumulative *big.Int
+	// Timestamp is the seconds the accumulators are last updated at
+	Timestamp uint64
+}
+
+// CumulativePricePool is implemented by pools which keep price accumulators on chain
+type CumulativePricePool interface {
+	CumulativePrices() CumulativePrices
+}
+
+// ReservesTimestampPool is implemented by pools which record the seconds their reserves last changed at
+type ReservesTimestampPool interface {
+	ReservesTimestamp() uint64
+}
+
 type TradingPoolProvider interface {
 	LoadPoolList() []TradingPool
 	SetResourceTypes(resourceTypes []string)
 }
 
+// PoolLoader is implemented by providers which report the resources they could not load as pools
+type PoolLoader interface {
+	// LoadPools return the pools loaded and an error for the resources which are not, usually PoolErrors
+	LoadPools() ([]TradingPool, error)
+}
+
 // TradeStep is a single trade step involving a Pool and a direction (X-to-Y or Y-to-X)
 type TradeStep struct {
 	Pool   TradingPool
@@ -130,6 +213,18 @@ type TradeRoute struct {
 type RouteAndQuote struct {
 	Route TradeRoute
 	Quote *QuoteType
+	// GasCost is the estimated gas cost in octas, nil if the aggregator has no gas model
+	GasCost *big.Int
+	// NetOutputAmount is the output amount minus gas cost valued in output coin
+	NetOutputAmount TokenAmount
+}
+
+// String format the quote in whole coins of the route, eg. 1.5 APT → 12.34 USDC
+func (r RouteAndQuote) String() string {
+	if r.Quote == nil {
+		return "no quote"
+	}
+	return r.Quote.Format(r.Route.XCoinInfo(), r.Route.YCoinInfo())
 }
 
 func NewTradeRoute(steps []TradeStep) TradeRoute {
@@ -209,18 +304,26 @@ func (tr *TradeRoute) HasRoundTrip() bool {
 	return false
 }
 
-// TryMakeRawPayload return raw router payload when step length is 1
-// raw router payload will cost less gas then hippo on_step_route
-func (tr *TradeRoute) TryMakeRawPayload(inputAmount, minOutAmount *big.Int) (types.EntryFunctionPayload, bool) {
+// CanMakeRawPayload report whether TryMakeRawPayload will return a raw router payload
+func (tr *TradeRoute) CanMakeRawPayload() bool {
 	if len(tr.Steps) > 1 {
-		return types.EntryFunctionPayload{}, false
+		return false
 	}
 	switch tr.Steps[0].Pool.DexType() {
 	case Aux, Pancake, Pontem:
-		return tr.Steps[0].Pool.MakePayload(inputAmount, minOutAmount, tr.Steps[0].IsXtoY), true
+		return true
 	default:
+		return false
+	}
+}
+
+// TryMakeRawPayload return raw router payload when step length is 1
+// raw router payload will cost less gas then hippo on_step_route
+func (tr *TradeRoute) TryMakeRawPayload(inputAmount, minOutAmount *big.Int) (types.EntryFunctionPayload, bool) {
+	if !tr.CanMakeRawPayload() {
 		return types.EntryFunctionPayload{}, false
 	}
+	return tr.Steps[0].Pool.MakePayload(inputAmount, minOutAmount, tr.Steps[0].IsXtoY), true
 }
 
 func (tr *TradeRoute) MakePayload(inputAmount, minOutAmount *big.Int) types.EntryFunctionPayload {
@@ -1,17 +1,19 @@
 package basiq
 
 import (
+	"errors"
+	"fmt"
 	"math/big"
-	"strconv"
-	"strings"
 
 	"github.com/coming-chat/go-aptos/aptosclient"
+	"github.com/coming-chat/go-aptos/aptostypes"
 	"github.com/omnibtc/go-hippo-sdk/aggregator/base"
 	"github.com/omnibtc/go-hippo-sdk/aggregator/coinlist"
 	"github.com/omnibtc/go-hippo-sdk/types"
 )
 
 type TradingPool struct {
+	poolId       base.PoolId
 	xCoinInfo    types.CoinInfo
 	yCoinInfo    types.CoinInfo
 	ownerAddress string
@@ -31,6 +33,10 @@ func NewTradingPool() base.TradingPool {
 	return &TradingPool{}
 }
 
+func (t *TradingPool) PoolId() base.PoolId {
+	return t.poolId
+}
+
 func (t *TradingPool) DexType() base.DexType {
 	return base.Basiq
 }
@@ -55,6 +61,10 @@ func (t *TradingPool) IsStateLoaded() bool {
 	return t.coinXReserve != nil && t.coinYReserve != nil
 }
 
+func (t *TradingPool) Reserves() (reserveX, reserveY *big.Int) {
+	return t.coinXReserve, t.coinYReserve
+}
+
 // ReloadState() error
 func (t *TradingPool) GetPrice() base.PriceType {
 	panic("not implemented") // TODO: Implement
@@ -110,96 +120,66 @@ func (t *TradingPool) MakePayload(input base.TokenAmount, minOut base.TokenAmoun
 	panic("not implemented") // TODO: Implement
 }
 
-type BasiqPoolProvider struct {
-	client         *aptosclient.RestClient
-	ownerAddress   string
-	coinListClient *coinlist.CoinListClient
-	resourceTypes  []string
+// poolResource is the json layout of basiq dex::BasiqPoolV1
+type poolResource struct {
+	XReserve           types.Coin `json:"x_reserve"`
+	YReserve           types.Coin `json:"y_reserve"`
+	FeeBips            types.Uint `json:"fee_bips"`
+	RebateBips         types.Uint `json:"rebate_bips"`
+	XDecimalAdjustment types.Uint `json:"x_decimal_adjustment"`
+	YDecimalAdjustment types.Uint `json:"y_decimal_adjustment"`
+	XPrice             types.Uint `json:"x_price"`
+	YPrice             types.Uint `json:"y_price"`
 }
 
-func NewPoolProvider(client *aptosclient.RestClient, ownerAddress string, coinListClient *coinlist.CoinListClient) base.TradingPoolProvider {
-	return &BasiqPoolProvider{
-		client:         client,
-		ownerAddress:   ownerAddress,
-		coinListClient: coinListClient,
-	}
-}
-func (p *BasiqPoolProvider) SetResourceTypes(resourceTypes []string) {
-	if len(resourceTypes) == 0 {
-		return
-	}
-	p.resourceTypes = resourceTypes
+func (r poolResource) Validate() error {
+	return types.RequireFields(map[string]types.Field{
+		"x_reserve":            r.XReserve,
+		"y_reserve":            r.YReserve,
+		"fee_bips":             r.FeeBips,
+		"rebate_bips":          r.RebateBips,
+		"x_decimal_adjustment": r.XDecimalAdjustment,
+		"y_decimal_adjustment": r.YDecimalAdjustment,
+		"x_price":              r.XPrice,
+		"y_price":              r.YPrice,
+	})
 }
 
-func (p *BasiqPoolProvider) LoadPoolList() []base.TradingPool {
-	poolList := make([]base.TradingPool, 0)
-	resources, err := p.client.GetAccountResources(p.ownerAddress, 0)
-	if err != nil {
-		for _, resourceType := range p.resourceTypes {
-			resource, err := p.client.GetAccountResource(p.ownerAddress, resourceType, 0)
-			if err != nil {
-				continue
+func NewPoolProvider(client *aptosclient.RestClient, ownerAddress string, coinListClient *coinlist.CoinListClient) base.TradingPoolProvider {
+	return base.NewPoolProvider(client, ownerAddress, coinListClient, base.PoolSpec{
+		ResourceMatcher: base.ContainsMatcher("dex::BasiqPoolV1"),
+		CoinTypeParams:  [2]int{0, 1},
+		ResourceTypes: func(x, y string) []string {
+			return []string{fmt.Sprintf("%s::dex::BasiqPoolV1<%s, %s>", ownerAddress, x, y)}
+		},
+		Decode: func(ctx base.PoolContext, resource aptostypes.AccountResource) (base.TradingPool, error) {
+			var data poolResource
+			if err := base.DecodeResource(resource, &data); err != nil {
+				return nil, err
 			}
-			resources = append(resources, *resource)
-		}
-	}
-
-	for _, resource := range resources {
-		if !strings.Contains(resource.Type, "dex::BasiqPoolV1") {
-			continue
-		}
-		tag, err := types.ParseMoveStructTag(resource.Type)
-		if err != nil {
-			// todo handle error
-			continue
-		}
-		if len(tag.TypeParams) < 2 {
-			continue
-		}
-		xTag := tag.TypeParams[0].StructTag
-		yTag := tag.TypeParams[1].StructTag
-		if nil == xTag || nil == yTag {
-			continue
-		}
-		xCoinInfo, bx := p.coinListClient.GetCoinInfoByType(xTag)
-		yCoinInfo, by := p.coinListClient.GetCoinInfoByType(yTag)
-		if !bx || !by {
-			continue
-		}
-		x := resource.Data["x_reserve"].(map[string]interface{})["value"].(string)
-		y := resource.Data["y_reserve"].(map[string]interface{})["value"].(string)
-		if x == "0" || y == "0" {
-			continue
-		}
-		xint, b := big.NewInt(0).SetString(x, 10)
-		if !b {
-			continue
-		}
-		yint, b := big.NewInt(0).SetString(y, 10)
-		if !b {
-			continue
-		}
-		feeBips, _ := strconv.Atoi(resource.Data["fee_bips"].(string))
-		rebateBips, _ := strconv.Atoi(resource.Data["rebate_bips"].(string))
-		xDecimalAdjustment, _ := big.NewInt(0).SetString(resource.Data["x_decimal_adjustment"].(string), 10)
-		yDecimalAdjustment, _ := big.NewInt(0).SetString(resource.Data["y_decimal_adjustment"].(string), 10)
-		xPrice, _ := big.NewInt(0).SetString(resource.Data["x_price"].(string), 10)
-		yPrice, _ := big.NewInt(0).SetString(resource.Data["y_price"].(string), 10)
-		poolList = append(poolList, &TradingPool{
-			xCoinInfo:          xCoinInfo,
-			yCoinInfo:          yCoinInfo,
-			feeBips:            feeBips,
-			coinXReserve:       xint,
-			coinYReserve:       yint,
-			ownerAddress:       p.ownerAddress,
-			rebateBips:         rebateBips,
-			xDecimalAdjustment: xDecimalAdjustment,
-			yDecimalAdjustment: yDecimalAdjustment,
-			xPrice:             xPrice,
-			yPrice:             yPrice,
-		})
-	}
-	return poolList
+			if data.XReserve.Value.Sign() == 0 || data.YReserve.Value.Sign() == 0 {
+				return nil, base.ErrEmptyPool
+			}
+			if data.XDecimalAdjustment.BigInt().Sign() == 0 || data.YDecimalAdjustment.BigInt().Sign() == 0 ||
+				data.XPrice.BigInt().Sign() == 0 || data.YPrice.BigInt().Sign() == 0 {
+				return nil, errors.New("invalid basiq pool price")
+			}
+			return &TradingPool{
+				poolId:             base.NewPoolId(base.Basiq, ctx.OwnerAddress, ctx.Tag),
+				xCoinInfo:          ctx.XCoinInfo,
+				yCoinInfo:          ctx.YCoinInfo,
+				feeBips:            int(data.FeeBips.BigInt().Int64()),
+				coinXReserve:       data.XReserve.Value,
+				coinYReserve:       data.YReserve.Value,
+				ownerAddress:       ctx.OwnerAddress,
+				rebateBips:         int(data.RebateBips.BigInt().Int64()),
+				xDecimalAdjustment: data.XDecimalAdjustment.BigInt(),
+				yDecimalAdjustment: data.YDecimalAdjustment.BigInt(),
+				xPrice:             data.XPrice.BigInt(),
+				yPrice:             data.YPrice.BigInt(),
+			}, nil
+		},
+	})
 }
 
 func calcSwapOutput(
@@ -1,13 +1,44 @@
 package coinlist
 
 import (
+	"reflect"
+	"strings"
+	"sync"
+
 	"github.com/omnibtc/go-hippo-sdk/contract"
 	"github.com/omnibtc/go-hippo-sdk/types"
 )
 
+// DiscoverParallelism is the max number of coins discovered concurrently
+const DiscoverParallelism = 8
+
+// CoinListChange is what a reload or a discovered coin changed in the list
+type CoinListChange struct {
+	// Version is the version of the list after the change
+	Version uint64
+	Added   []types.CoinInfo
+	Removed []types.CoinInfo
+	// Updated coins are still in the list with different info
+	Updated []types.CoinInfo
+	// Discovered is true if the Added coins were discovered on chain, eg. while pools are parsed, rather than fetched by Reload
+	Discovered bool
+}
+
+func (c CoinListChange) isEmpty() bool {
+	return len(c.Added) == 0 && len(c.Removed) == 0 && len(c.Updated) == 0
+}
+
+// CoinListClient cache the coin list of app, it is safe for concurrent use and can be reloaded at any time
 type CoinListClient struct {
+	lock               sync.RWMutex
 	fullNameToCoinInfo map[string]types.CoinInfo
 	coinList           []types.CoinInfo
+	loaded             bool
+	version            uint64
+
+	subscribersLock sync.Mutex
+	subscribers     map[int]func(change CoinListChange)
+	nextSubscriber  int
 
 	app contract.App
 }
@@ -17,38 +48,248 @@ func LoadCoinListClient(app contract.App) (*CoinListClient, error) {
 		app:                app,
 		fullNameToCoinInfo: make(map[string]types.CoinInfo, 0),
 		coinList:           make([]types.CoinInfo, 0),
+		subscribers:        make(map[int]func(change CoinListChange)),
 	}
-	err := c.buildCache()
+	err := c.Reload()
 	return c, err
 }
 
+// Reload fetch the coin list again and notify subscribers if it changed,
+// the cached list is kept if fetching fails
+func (c *CoinListClient) Reload() error {
+	fullList, err := c.app.CoinList.QueryFetchFullList()
+	if err != nil {
+		return err
+	}
+	fullNameToCoinInfo := make(map[string]types.CoinInfo, len(fullList))
+	for _, tokenInfo := range fullList {
+		fullNameToCoinInfo[tokenInfo.TokenType.GetFullName()] = tokenInfo
+	}
+
+	c.lock.Lock()
+	change := CoinListChange{}
+	for fullName, coin := range fullNameToCoinInfo {
+		old, ok := c.fullNameToCoinInfo[fullName]
+		if !ok {
+			change.Added = append(change.Added, coin)
+		} else if !reflect.DeepEqual(old, coin) {
+			change.Updated = append(change.Updated, coin)
+		}
+	}
+	for fullName, coin := range c.fullNameToCoinInfo {
+		if _, ok := fullNameToCoinInfo[fullName]; !ok {
+			change.Removed = append(change.Removed, coin)
+		}
+	}
+	c.fullNameToCoinInfo = fullNameToCoinInfo
+	// copy the list, the coin list app may modify its own slice
+	c.coinList = append(make([]types.CoinInfo, 0, len(fullList)), fullList...)
+	c.loaded = true
+	if !change.isEmpty() {
+		c.version++
+		change.Version = c.version
+	}
+	c.lock.Unlock()
+
+	c.notify(change)
+	return nil
+}
+
+// IsLoaded is false until the coin list is fetched successfully
+func (c *CoinListClient) IsLoaded() bool {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.loaded
+}
+
+// Version increase on every change of the list
+func (c *CoinListClient) Version() uint64 {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.version
+}
+
+// Subscribe call fn after each change of the list, until unsubscribe is called.
+// fn is called without any lock held, so it may call back into the client or reload pools,
+// it may be called concurrently for concurrent changes, Version tells their order.
+func (c *CoinListClient) Subscribe(fn func(change CoinListChange)) (unsubscribe func()) {
+	c.subscribersLock.Lock()
+	defer c.subscribersLock.Unlock()
+	id := c.nextSubscriber
+	c.nextSubscriber++
+	c.subscribers[id] = fn
+	return func() {
+		c.subscribersLock.Lock()
+		defer c.subscribersLock.Unlock()
+		delete(c.subscribers, id)
+	}
+}
+
+func (c *CoinListClient) notify(change CoinListChange) {
+	if change.isEmpty() {
+		return
+	}
+	c.subscribersLock.Lock()
+	subscribers := make([]func(change CoinListChange), 0, len(c.subscribers))
+	for _, fn := range c.subscribers {
+		subscribers = append(subscribers, fn)
+	}
+	c.subscribersLock.Unlock()
+	for _, fn := range subscribers {
+		fn(change)
+	}
+}
+
 func (c *CoinListClient) HasTokenType(tokenType types.TokenType) bool {
-	_, ok := c.fullNameToCoinInfo[tokenType.GetFullName()]
+	_, ok := c.GetCoinInfoByType(tokenType)
 	return ok
 }
 
 func (c *CoinListClient) GetCoinInfoList() []types.CoinInfo {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
 	return c.coinList
 }
 
 func (c *CoinListClient) GetCoinInfoByType(tokenType types.TokenType) (types.CoinInfo, bool) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
 	v, o := c.fullNameToCoinInfo[tokenType.GetFullName()]
 	return v, o
 }
 
+// GetCoinInfoByFullName find a coin by type name, in any address form or spacing
 func (c *CoinListClient) GetCoinInfoByFullName(fullName string) (types.CoinInfo, bool) {
-	v, o := c.fullNameToCoinInfo[fullName]
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	v, o := c.fullNameToCoinInfo[types.CanonicalTypeName(fullName)]
 	return v, o
 }
 
-func (c *CoinListClient) buildCache() error {
-	fullList, err := c.app.CoinList.QueryFetchFullList()
-	if err != nil {
-		return err
+// GetCoinInfoBySymbol find a coin by symbol, ignoring case.
+// Symbols are not unique, eg. bridged coins, if several coins have it the first verified one in list is returned.
+func (c *CoinListClient) GetCoinInfoBySymbol(symbol string) (types.CoinInfo, bool) {
+	var found *types.CoinInfo
+	for _, coin := range c.GetCoinInfoList() {
+		if !strings.EqualFold(coin.Symbol, symbol) {
+			continue
+		}
+		if coin.IsVerified() {
+			return coin, true
+		}
+		if found == nil {
+			coin := coin
+			found = &coin
+		}
 	}
-	for _, tokenInfo := range fullList {
-		c.fullNameToCoinInfo[tokenInfo.TokenType.GetFullName()] = tokenInfo
+	if found == nil {
+		return types.CoinInfo{}, false
 	}
-	c.coinList = fullList
-	return nil
+	return *found, true
+}
+
+// GetCoinInfosBySymbol return every coin whose symbol or official symbol is symbol, ignoring case, in list order
+func (c *CoinListClient) GetCoinInfosBySymbol(symbol string) []types.CoinInfo {
+	return c.filter(func(coin types.CoinInfo) bool {
+		return strings.EqualFold(coin.Symbol, symbol) || strings.EqualFold(coin.OfficialSymbol, symbol)
+	})
+}
+
+// GetCoinInfosByTag return every coin with tag in list order
+func (c *CoinListClient) GetCoinInfosByTag(tag types.CoinTag) []types.CoinInfo {
+	return c.filter(func(coin types.CoinInfo) bool {
+		return coin.HasTag(tag)
+	})
+}
+
+// GetVerifiedCoinInfoList return the coins which are not discovered on chain
+func (c *CoinListClient) GetVerifiedCoinInfoList() []types.CoinInfo {
+	return c.filter(types.CoinInfo.IsVerified)
+}
+
+func (c *CoinListClient) filter(match func(coin types.CoinInfo) bool) []types.CoinInfo {
+	result := make([]types.CoinInfo, 0)
+	for _, coin := range c.GetCoinInfoList() {
+		if match(coin) {
+			result = append(result, coin)
+		}
+	}
+	return result
+}
+
+// GetOrDiscoverCoinInfo find a coin in the list, if it is missing and the coin list app is a contract.CoinDiscoverer,
+// the coin is discovered on chain and added to the list as unverified
+func (c *CoinListClient) GetOrDiscoverCoinInfo(tokenType types.TokenType) (types.CoinInfo, bool) {
+	if coin, ok := c.GetCoinInfoByType(tokenType); ok {
+		return coin, true
+	}
+	c.DiscoverCoinInfos([]types.TokenType{tokenType})
+	return c.GetCoinInfoByFullName(tokenType.GetFullName())
+}
+
+// DiscoverCoinInfos discover the coins of tokenTypes which are not in the list, with at most
+// DiscoverParallelism requests in flight, and add them to the list as unverified in a single change.
+// It returns the added coins, coins which can not be discovered are skipped.
+func (c *CoinListClient) DiscoverCoinInfos(tokenTypes []types.TokenType) []types.CoinInfo {
+	discoverer, ok := c.app.CoinList.(contract.CoinDiscoverer)
+	if !ok {
+		return nil
+	}
+	missing := make([]types.TokenType, 0)
+	seen := make(map[string]bool)
+	for _, tokenType := range tokenTypes {
+		fullName := types.CanonicalTypeName(tokenType.GetFullName())
+		if seen[fullName] || c.HasTokenType(tokenType) {
+			continue
+		}
+		seen[fullName] = true
+		missing = append(missing, tokenType)
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	discovered := make([]*types.CoinInfo, len(missing))
+	sem := make(chan struct{}, DiscoverParallelism)
+	wg := sync.WaitGroup{}
+	for i, tokenType := range missing {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, tokenType types.TokenType) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			coin, err := discoverer.DiscoverCoinInfo(tokenType)
+			if err != nil {
+				return
+			}
+			discovered[i] = &coin
+		}(i, tokenType)
+	}
+	wg.Wait()
+
+	c.lock.Lock()
+	added := make([]types.CoinInfo, 0)
+	for _, coin := range discovered {
+		if coin == nil {
+			continue
+		}
+		fullName := coin.TokenType.GetFullName()
+		if _, ok := c.fullNameToCoinInfo[fullName]; ok {
+			continue
+		}
+		c.fullNameToCoinInfo[fullName] = *coin
+		added = append(added, *coin)
+	}
+	change := CoinListChange{}
+	if len(added) > 0 {
+		// always copy the list, readers and the coin list app may hold it
+		c.coinList = append(c.coinList[:len(c.coinList):len(c.coinList)], added...)
+		c.version++
+		change = CoinListChange{Version: c.version, Added: added, Discovered: true}
+	}
+	c.lock.Unlock()
+	c.notify(change)
+	return added
 }
@@ -0,0 +1,149 @@
+package coinlist
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/omnibtc/go-hippo-sdk/contract"
+	"github.com/omnibtc/go-hippo-sdk/types"
+)
+
+func TestCoinListClient_Lookups(t *testing.T) {
+	unverified := types.CoinInfo{
+		Symbol:     "USDC",
+		TokenType:  &types.StructTag{Address: "0x2", Module: "fake", Name: "USDC"},
+		Unverified: true,
+	}
+	tagged, err := contract.NewReaderCoinListApp(strings.NewReader(`[
+		{"name": "USD Coin (Wormhole)", "symbol": "USDC", "official_symbol": "USDC", "decimals": 6, "tags": ["stable"],
+			"token_type": {"type": "0x5e156f1207d0ebfa19a9eeff00d62a282278fb8719f4fab3a586a0a2c0fffbea::coin::T"},
+			"extensions": {"data": [["bridge", "wormhole"]]}},
+		{"name": "USD Coin (Celer)", "symbol": "ceUSDC", "official_symbol": "USDC", "decimals": 6, "tags": ["stable"],
+			"token_type": {"type": "0x8d87a65ba30e09357fa2edea2c80dbac296e5dec2b18287113500b902942929d::celer_coin_manager::UsdcCoin"},
+			"extensions": {"data": [["bridge", "celer"]]}}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := LoadCoinListClient(contract.App{CoinList: contract.NewMergedCoinListApp(
+		tagged,
+		contract.NewCustomCoinListApp([]types.CoinInfo{unverified}),
+		contract.NewEmbeddedCoinListApp(),
+	)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	usdc, ok := c.GetCoinInfoBySymbol("usdc")
+	if !ok || !usdc.IsVerified() || usdc.Bridge != "wormhole" || !usdc.HasTag(types.StableCoinTag) {
+		t.Errorf("GetCoinInfoBySymbol() = %+v", usdc)
+	}
+	// every bridged USDC and the unverified one
+	if got := c.GetCoinInfosBySymbol("USDC"); len(got) < 5 {
+		t.Errorf("GetCoinInfosBySymbol() = %d coins", len(got))
+	}
+	// only the tagged list has tags
+	if got := c.GetCoinInfosByTag(types.WrappedCoinTag); len(got) != 2 || got[0].Bridge != "wormhole" {
+		t.Errorf("GetCoinInfosByTag(wrapped) = %+v", got)
+	}
+	if got, all := len(c.GetVerifiedCoinInfoList()), len(c.GetCoinInfoList()); got != all-1 {
+		t.Errorf("GetVerifiedCoinInfoList() = %d, want %d", got, all-1)
+	}
+	ceUsdc, ok := c.GetCoinInfoBySymbol("ceUSDC")
+	if !ok || ceUsdc.Bridge != "celer" || ceUsdc.OfficialSymbol != "USDC" {
+		t.Errorf("GetCoinInfoBySymbol(ceUSDC) = %+v", ceUsdc)
+	}
+}
+
+func TestCoinListClient_Reload(t *testing.T) {
+	apt := types.CoinInfo{Symbol: "APT", TokenType: &types.StructTag{Address: "0x1", Module: "aptos_coin", Name: "AptosCoin"}}
+	usdc := types.CoinInfo{Symbol: "USDC", TokenType: &types.StructTag{Address: "0x2", Module: "usdc", Name: "USDC"}}
+	app := &contract.CustomCoinListApp{}
+	app.Append([]types.CoinInfo{apt})
+	c, err := LoadCoinListClient(contract.App{CoinList: app})
+	if err != nil {
+		t.Fatal(err)
+	}
+	changes := make([]CoinListChange, 0)
+	unsubscribe := c.Subscribe(func(change CoinListChange) {
+		changes = append(changes, change)
+	})
+
+	if err := c.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 0 {
+		t.Errorf("Reload() without change notified %d changes", len(changes))
+	}
+	app.Clear()
+	app.Append([]types.CoinInfo{usdc})
+	if err := c.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 || len(changes[0].Added) != 1 || len(changes[0].Removed) != 1 || changes[0].Version != c.Version() {
+		t.Fatalf("changes = %+v", changes)
+	}
+	if c.HasTokenType(apt.TokenType) || !c.HasTokenType(usdc.TokenType) {
+		t.Errorf("Reload() did not replace the list")
+	}
+
+	unsubscribe()
+	app.Append([]types.CoinInfo{apt})
+	if err := c.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 {
+		t.Errorf("notified after unsubscribe")
+	}
+}
+
+// discoverApp discover every coin but MISSING
+type discoverApp struct {
+	*contract.CustomCoinListApp
+	lock      sync.Mutex
+	requested []string
+}
+
+func (a *discoverApp) DiscoverCoinInfo(tokenType types.TokenType) (types.CoinInfo, error) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.requested = append(a.requested, tokenType.GetFullName())
+	tag := tokenType.(*types.StructTag)
+	if tag.Name == "MISSING" {
+		return types.CoinInfo{}, errors.New("not found")
+	}
+	return types.CoinInfo{Symbol: tag.Name, TokenType: tag, Unverified: true}, nil
+}
+
+func TestCoinListClient_DiscoverCoinInfos(t *testing.T) {
+	apt := types.CoinInfo{Symbol: "APT", TokenType: &types.StructTag{Address: "0x1", Module: "aptos_coin", Name: "AptosCoin"}}
+	app := &discoverApp{CustomCoinListApp: &contract.CustomCoinListApp{}}
+	app.Append([]types.CoinInfo{apt})
+	c, err := LoadCoinListClient(contract.App{CoinList: app})
+	if err != nil {
+		t.Fatal(err)
+	}
+	changes := make([]CoinListChange, 0)
+	c.Subscribe(func(change CoinListChange) {
+		changes = append(changes, change)
+	})
+
+	tokenTypes := []types.TokenType{apt.TokenType, &types.StructTag{Address: "0x2", Module: "coin", Name: "MISSING"}}
+	for _, name := range []string{"A", "B", "C", "A"} {
+		tokenTypes = append(tokenTypes, &types.StructTag{Address: "0x2", Module: "coin", Name: name})
+	}
+	added := c.DiscoverCoinInfos(tokenTypes)
+	if len(added) != 3 || len(app.requested) != 4 {
+		t.Errorf("DiscoverCoinInfos() added %d coins with %d requests, want 3 and 4", len(added), len(app.requested))
+	}
+	if len(changes) != 1 || len(changes[0].Added) != 3 || !changes[0].Discovered {
+		t.Fatalf("changes = %+v, want one change discovering 3 coins", changes)
+	}
+	if coin, ok := c.GetCoinInfoBySymbol("B"); !ok || coin.IsVerified() {
+		t.Errorf("GetCoinInfoBySymbol(B) = %+v, %t", coin, ok)
+	}
+	if added = c.DiscoverCoinInfos(tokenTypes[:3]); len(added) != 0 || len(changes) != 1 {
+		t.Errorf("DiscoverCoinInfos() of known coins added %d, notified %d changes", len(added), len(changes))
+	}
+}
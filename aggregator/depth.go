@@ -0,0 +1,146 @@
+package aggregator
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/omnibtc/go-hippo-sdk/aggregator/base"
+	"github.com/omnibtc/go-hippo-sdk/types"
+)
+
+// DepthPoint is the largest trade whose average price is within a price level
+type DepthPoint struct {
+	// LevelBps is the price level, how much the average price may be worse than the best spot price
+	LevelBps     uint64
+	InputAmount  *big.Int
+	OutputAmount *big.Int
+	// SlippageBps is how much the average price of this trade is worse than the best spot price
+	SlippageBps uint64
+}
+
+// VenueDepth is the depth of a single pool or route
+type VenueDepth struct {
+	Route  base.TradeRoute
+	Points []DepthPoint
+}
+
+// Depth is the liquidity from x to y at each price level
+type Depth struct {
+	// SpotRate is the best output per input of a tiny trade over all venues, price levels are relative to it
+	SpotRate *big.Rat
+	// Combined is the sum of the depth of every direct pool,
+	// an order split across the pools this way fills within each level
+	Combined []DepthPoint
+	// BestRoute is the depth of the best route for one unit of x, nil if x can not be swapped to y
+	BestRoute *VenueDepth
+	// Pools is the depth of each direct pool
+	Pools []VenueDepth
+}
+
+// GetDepth sample how much y each direct pool and the best route give for increasing input of x,
+// priceLevels are in bps of the best spot price, eg. 10, 50, 100
+func (a *TradeAggregator) GetDepth(x, y types.CoinInfo, priceLevels []uint64) (*Depth, error) {
+	if x.TokenType.GetFullName() == y.TokenType.GetFullName() {
+		return nil, fmt.Errorf("can not get depth of %s against itself", x.Symbol)
+	}
+	routes := make([]base.TradeRoute, 0)
+	for _, step := range a.GetXtoYDirectSteps(x, y, false) {
+		routes = append(routes, base.NewTradeRoute([]base.TradeStep{step}))
+	}
+	best, err := a.GetBestQuote(x.Unit(), x, y, RouteOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	// the best spot rate over all venues is the reference of price levels
+	var spot *big.Rat
+	venues := routes
+	if best != nil {
+		venues = append(venues[:len(venues):len(venues)], best.Route)
+	}
+	for _, route := range venues {
+		reserve := routeInputReserve(route)
+		if reserve == nil || reserve.Sign() <= 0 {
+			continue
+		}
+		rate, err := spotRate(route, reserve)
+		if err != nil {
+			continue
+		}
+		if spot == nil || rate.Cmp(spot) > 0 {
+			spot = rate
+		}
+	}
+	depth := &Depth{
+		SpotRate: spot,
+		Combined: make([]DepthPoint, len(priceLevels)),
+		Pools:    make([]VenueDepth, 0, len(routes)),
+	}
+	for i, level := range priceLevels {
+		depth.Combined[i] = DepthPoint{LevelBps: level, InputAmount: big.NewInt(0), OutputAmount: big.NewInt(0)}
+	}
+	if spot == nil {
+		return depth, nil
+	}
+
+	for _, route := range routes {
+		venue := getVenueDepth(route, spot, priceLevels)
+		for i, p := range venue.Points {
+			depth.Combined[i].InputAmount.Add(depth.Combined[i].InputAmount, p.InputAmount)
+			depth.Combined[i].OutputAmount.Add(depth.Combined[i].OutputAmount, p.OutputAmount)
+		}
+		depth.Pools = append(depth.Pools, venue)
+	}
+	for i := range depth.Combined {
+		depth.Combined[i].SlippageBps = slippageBps(spot, depth.Combined[i].InputAmount, depth.Combined[i].OutputAmount)
+	}
+	if best != nil {
+		venue := getVenueDepth(best.Route, spot, priceLevels)
+		depth.BestRoute = &venue
+	}
+	return depth, nil
+}
+
+func getVenueDepth(route base.TradeRoute, spot *big.Rat, priceLevels []uint64) VenueDepth {
+	venue := VenueDepth{
+		Route:  route,
+		Points: make([]DepthPoint, len(priceLevels)),
+	}
+	reserve := routeInputReserve(route)
+	for i, level := range priceLevels {
+		input := big.NewInt(0)
+		if reserve != nil && reserve.Sign() > 0 {
+			if level >= 10000 {
+				input = big.NewInt(0).Set(reserve)
+			} else {
+				minRate := new(big.Rat).Mul(spot, big.NewRat(int64(10000-level), 10000))
+				input = searchMaxInputAtRate(route, minRate, reserve)
+			}
+		}
+		output := big.NewInt(0)
+		if input.Sign() > 0 {
+			output = route.GetQuote(input).OutputAmount
+		}
+		venue.Points[i] = DepthPoint{
+			LevelBps:     level,
+			InputAmount:  input,
+			OutputAmount: output,
+			SlippageBps:  slippageBps(spot, input, output),
+		}
+	}
+	return venue
+}
+
+// slippageBps return how much output/input is worse than spot in bps, 0 for no input
+func slippageBps(spot *big.Rat, input, output *big.Int) uint64 {
+	if input.Sign() <= 0 {
+		return 0
+	}
+	// 10000 * (1 - output / (input * spot))
+	ratio := new(big.Rat).Quo(new(big.Rat).SetFrac(output, input), spot)
+	loss := new(big.Rat).Sub(big.NewRat(1, 1), ratio)
+	if loss.Sign() <= 0 {
+		return 0
+	}
+	return ratFloor(loss.Mul(loss, big.NewRat(10000, 1))).Uint64()
+}
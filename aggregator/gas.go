@@ -0,0 +1,109 @@
+package aggregator
+
+import (
+	"math/big"
+	"sync"
+
+	"github.com/coming-chat/go-aptos/aptostypes"
+	"github.com/omnibtc/go-hippo-sdk/aggregator/base"
+)
+
+// AptosCoinFullName is the coin gas is paid in
+const AptosCoinFullName = "0x1::aptos_coin::AptosCoin"
+
+// DefaultGasUnitPrice is the gas unit price in octas used by NewGasModel
+const DefaultGasUnitPrice = 100
+
+// gasLearnWeight is the weight of the old estimate when learning gas units, new = (old*(w-1) + observed) / w
+const gasLearnWeight = 4
+
+// GasModel estimate the gas cost of a route from the number of steps and whether it can use a raw DEX payload
+type GasModel struct {
+	lock         sync.RWMutex
+	gasUnitPrice uint64
+	hippoUnits   map[int]uint64
+	rawUnits     map[int]uint64
+}
+
+// NewGasModel return a gas model with rough default gas units, use SetGasUnits or Observe to tune it
+func NewGasModel(gasUnitPrice uint64) *GasModel {
+	if gasUnitPrice == 0 {
+		gasUnitPrice = DefaultGasUnitPrice
+	}
+	return &GasModel{
+		gasUnitPrice: gasUnitPrice,
+		hippoUnits: map[int]uint64{
+			1: 1500,
+			2: 2500,
+			3: 3500,
+		},
+		rawUnits: map[int]uint64{
+			1: 800,
+		},
+	}
+}
+
+func (m *GasModel) SetGasUnitPrice(gasUnitPrice uint64) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.gasUnitPrice = gasUnitPrice
+}
+
+func (m *GasModel) GasUnitPrice() uint64 {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	return m.gasUnitPrice
+}
+
+// SetGasUnits set the gas units of a route with steps, raw is true for raw DEX payload
+func (m *GasModel) SetGasUnits(steps int, raw bool, units uint64) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.getUnits(raw)[steps] = units
+}
+
+func (m *GasModel) GasUnits(steps int, raw bool) uint64 {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	if units, ok := m.getUnits(raw)[steps]; ok {
+		return units
+	}
+	// fallback to hippo payload, which supports every route
+	return m.hippoUnits[steps]
+}
+
+// Observe learn the gas units of a route from the gas used by a simulated or executed transaction
+func (m *GasModel) Observe(steps int, raw bool, gasUsed uint64) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	units := m.getUnits(raw)
+	if old, ok := units[steps]; ok {
+		units[steps] = (old*(gasLearnWeight-1) + gasUsed) / gasLearnWeight
+	} else {
+		units[steps] = gasUsed
+	}
+}
+
+// ObserveSimulation learn the gas units of route from a simulated transaction of its payload, failed transactions are ignored
+func (m *GasModel) ObserveSimulation(route *base.TradeRoute, txn *aptostypes.Transaction) {
+	if txn == nil || !txn.Success {
+		return
+	}
+	m.Observe(len(route.Steps), route.CanMakeRawPayload(), txn.GasUsed)
+}
+
+// EstimateRouteGas return the estimated gas cost of route in octas
+func (m *GasModel) EstimateRouteGas(route *base.TradeRoute) *big.Int {
+	units := m.GasUnits(len(route.Steps), route.CanMakeRawPayload())
+	return big.NewInt(0).Mul(
+		big.NewInt(0).SetUint64(units),
+		big.NewInt(0).SetUint64(m.GasUnitPrice()),
+	)
+}
+
+func (m *GasModel) getUnits(raw bool) map[int]uint64 {
+	if raw {
+		return m.rawUnits
+	}
+	return m.hippoUnits
+}
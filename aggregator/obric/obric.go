@@ -1,13 +1,14 @@
 package obric
 
 import (
+	"errors"
+	"fmt"
 	"github.com/coming-chat/go-aptos/aptosclient"
 	"github.com/coming-chat/go-aptos/aptostypes"
 	"github.com/omnibtc/go-hippo-sdk/aggregator/base"
 	"github.com/omnibtc/go-hippo-sdk/aggregator/coinlist"
 	"github.com/omnibtc/go-hippo-sdk/types"
 	"math/big"
-	"strings"
 )
 
 type PieceSwapPoolInfo struct {
@@ -28,99 +29,73 @@ type PieceSwapPoolInfo struct {
 	TypeTag                     types.StructTag
 }
 
-func NewPieceSwapPoolInfo(resource aptostypes.AccountResource) *PieceSwapPoolInfo {
-	data := resource.Data
-	tag, _ := types.ParseMoveStructTag(resource.Type)
-	k, b := big.NewInt(0).SetString(data["K"].(string), 10)
-	if !b {
-		return nil
-	}
-	k2, b := big.NewInt(0).SetString(data["K2"].(string), 10)
-	if !b {
-		return nil
-	}
-	xa, b := big.NewInt(0).SetString(data["Xa"].(string), 10)
-	if !b {
-		return nil
-	}
-
-	xb, b := big.NewInt(0).SetString(data["Xb"].(string), 10)
-	if !b {
-		return nil
-	}
-	m, b := big.NewInt(0).SetString(data["m"].(string), 10)
-	if !b {
-		return nil
-	}
-	n, b := big.NewInt(0).SetString(data["n"].(string), 10)
-	if !b {
-		return nil
-	}
-	protocolFeeSharePerThousand, b := big.NewInt(0).SetString(data["protocol_fee_share_per_thousand"].(string), 10)
-	if !b {
-		return nil
-	}
-	swapFeePerMillion, b := big.NewInt(0).SetString(data["swap_fee_per_million"].(string), 10)
-	if !b {
-		return nil
-	}
-	xDeciMult, b := big.NewInt(0).SetString(data["x_deci_mult"].(string), 10)
-	if !b {
-		return nil
-	}
-	yDeciMult, b := big.NewInt(0).SetString(data["y_deci_mult"].(string), 10)
-	if !b {
-		return nil
-	}
-	coinXValue, b := big.NewInt(0).SetString(data["reserve_x"].(map[string]interface{})["value"].(string), 10)
-	if !b {
-		return nil
-	}
-	reserveX := types.Coin{
-		Value: coinXValue,
-	}
-
-	coinYValue, b := big.NewInt(0).SetString(data["reserve_y"].(map[string]interface{})["value"].(string), 10)
-	if !b {
-		return nil
-	}
-	reserveY := types.Coin{
-		Value: coinYValue,
-	}
-
-	feeXValue, b := big.NewInt(0).SetString(data["protocol_fee_x"].(map[string]interface{})["value"].(string), 10)
-	if !b {
-		return nil
+// pieceSwapPoolResource is the json layout of obric piece_swap::PieceSwapPoolInfo
+type pieceSwapPoolResource struct {
+	K                           types.Uint `json:"K"`
+	K2                          types.Uint `json:"K2"`
+	Xa                          types.Uint `json:"Xa"`
+	Xb                          types.Uint `json:"Xb"`
+	M                           types.Uint `json:"m"`
+	N                           types.Uint `json:"n"`
+	ProtocolFeeSharePerThousand types.Uint `json:"protocol_fee_share_per_thousand"`
+	SwapFeePerMillion           types.Uint `json:"swap_fee_per_million"`
+	XDeciMult                   types.Uint `json:"x_deci_mult"`
+	YDeciMult                   types.Uint `json:"y_deci_mult"`
+	ReserveX                    types.Coin `json:"reserve_x"`
+	ReserveY                    types.Coin `json:"reserve_y"`
+	ProtocolFeeX                types.Coin `json:"protocol_fee_x"`
+	ProtocolFeeY                types.Coin `json:"protocol_fee_y"`
+}
+
+func (r pieceSwapPoolResource) Validate() error {
+	return types.RequireFields(map[string]types.Field{
+		"K":                               r.K,
+		"K2":                              r.K2,
+		"Xa":                              r.Xa,
+		"Xb":                              r.Xb,
+		"m":                               r.M,
+		"n":                               r.N,
+		"protocol_fee_share_per_thousand": r.ProtocolFeeSharePerThousand,
+		"swap_fee_per_million":            r.SwapFeePerMillion,
+		"x_deci_mult":                     r.XDeciMult,
+		"y_deci_mult":                     r.YDeciMult,
+		"reserve_x":                       r.ReserveX,
+		"reserve_y":                       r.ReserveY,
+		"protocol_fee_x":                  r.ProtocolFeeX,
+		"protocol_fee_y":                  r.ProtocolFeeY,
+	})
+}
+
+func NewPieceSwapPoolInfo(resource aptostypes.AccountResource) (*PieceSwapPoolInfo, error) {
+	tag, err := types.ParseMoveStructTag(resource.Type)
+	if err != nil {
+		return nil, err
 	}
-	protocolFeeX := types.Coin{
-		Value: feeXValue,
+	var data pieceSwapPoolResource
+	if err = base.DecodeResource(resource, &data); err != nil {
+		return nil, err
 	}
-
-	feeYValue, b := big.NewInt(0).SetString(data["protocol_fee_y"].(map[string]interface{})["value"].(string), 10)
-	if !b {
-		return nil
-	}
-	protocolFeeY := types.Coin{
-		Value: feeYValue,
+	if data.XDeciMult.BigInt().Sign() == 0 || data.YDeciMult.BigInt().Sign() == 0 {
+		return nil, errors.New("invalid obric decimal multiplier")
 	}
 
 	return &PieceSwapPoolInfo{
-		K:                           k,
-		K2:                          k2,
-		Xa:                          xa,
-		Xb:                          xb,
-		M:                           m,
-		N:                           n,
+		K:                           data.K.BigInt(),
+		K2:                          data.K2.BigInt(),
+		Xa:                          data.Xa.BigInt(),
+		Xb:                          data.Xb.BigInt(),
+		M:                           data.M.BigInt(),
+		N:                           data.N.BigInt(),
 		TypeTag:                     tag,
-		ReserveX:                    reserveX,
-		ReserveY:                    reserveY,
-		XDeciMult:                   xDeciMult,
-		YDeciMult:                   yDeciMult,
-		ProtocolFeeX:                protocolFeeX,
-		ProtocolFeeY:                protocolFeeY,
-		SwapFeePerMillion:           swapFeePerMillion,
-		ProtocolFeeSharePerThousand: protocolFeeSharePerThousand,
-	}
+		ReserveX:                    data.ReserveX,
+		ReserveY:                    data.ReserveY,
+		XDeciMult:                   data.XDeciMult.BigInt(),
+		YDeciMult:                   data.YDeciMult.BigInt(),
+		ProtocolFeeX:                data.ProtocolFeeX,
+		ProtocolFeeY:                data.ProtocolFeeY,
+		SwapFeePerMillion:           data.SwapFeePerMillion.BigInt(),
+		ProtocolFeeSharePerThousand: data.ProtocolFeeSharePerThousand.BigInt(),
+	}, nil
 }
 
 func (p *PieceSwapPoolInfo) quoteXToYAfterFees(amountXIn *big.Int) *big.Int {
@@ -152,21 +127,27 @@ func (p *PieceSwapPoolInfo) quoteYTox(amountYIn *big.Int) *big.Int {
 }
 
 type ObricTradingPool struct {
+	poolId    base.PoolId
 	pool      *PieceSwapPoolInfo
 	xCoinInfo types.CoinInfo
 	yCoinInfo types.CoinInfo
 }
 
-func NewObricTradingPool(xCoinInfo, yCoinInfo types.CoinInfo, resource aptostypes.AccountResource) base.TradingPool {
-	pool := NewPieceSwapPoolInfo(resource)
-	if pool == nil {
-		return nil
+func NewObricTradingPool(owner string, xCoinInfo, yCoinInfo types.CoinInfo, resource aptostypes.AccountResource) (base.TradingPool, error) {
+	pool, err := NewPieceSwapPoolInfo(resource)
+	if err != nil {
+		return nil, err
 	}
 	return &ObricTradingPool{
+		poolId:    base.NewPoolId(base.Obric, owner, pool.TypeTag),
 		pool:      pool,
 		xCoinInfo: xCoinInfo,
 		yCoinInfo: yCoinInfo,
-	}
+	}, nil
+}
+
+func (t *ObricTradingPool) PoolId() base.PoolId {
+	return t.poolId
 }
 
 func (t *ObricTradingPool) DexType() base.DexType {
@@ -193,6 +174,10 @@ func (t *ObricTradingPool) IsStateLoaded() bool {
 	return t.pool != nil
 }
 
+func (t *ObricTradingPool) Reserves() (reserveX, reserveY *big.Int) {
+	return t.pool.ReserveX.Value, t.pool.ReserveY.Value
+}
+
 // ReloadState() error
 func (t *ObricTradingPool) GetPrice() base.PriceType {
 	panic("not implemented")
@@ -232,71 +217,15 @@ func (t *ObricTradingPool) MakePayload(input base.TokenAmount, minOut base.Token
 	panic("not implemented")
 }
 
-type ObricPoolProvider struct {
-	client         *aptosclient.RestClient
-	ownerAddress   string
-	coinListClient *coinlist.CoinListClient
-	resourceTypes  []string
-}
-
 func NewPoolProvider(client *aptosclient.RestClient, ownerAddress string, coinListClient *coinlist.CoinListClient) base.TradingPoolProvider {
-	return &ObricPoolProvider{
-		client:         client,
-		ownerAddress:   ownerAddress,
-		coinListClient: coinListClient,
-	}
-}
-
-func (p *ObricPoolProvider) SetResourceTypes(resourceTypes []string) {
-	if len(resourceTypes) == 0 {
-		return
-	}
-	p.resourceTypes = resourceTypes
-}
-
-func (p *ObricPoolProvider) LoadPoolList() []base.TradingPool {
-	poolList := make([]base.TradingPool, 0)
-	resources, err := p.client.GetAccountResources(p.ownerAddress, 0)
-	if err != nil {
-		for _, resourceType := range p.resourceTypes {
-			resource, err := p.client.GetAccountResource(p.ownerAddress, resourceType, 0)
-			if err != nil {
-				continue
-			}
-			resources = append(resources, *resource)
-		}
-	}
-
-	for _, resource := range resources {
-		if !strings.Contains(resource.Type, "piece_swap::PieceSwapPoolInfo") {
-			continue
-		}
-		tag, err := types.ParseMoveStructTag(resource.Type)
-		if err != nil {
-			// todo handle error
-			continue
-		}
-		if len(tag.TypeParams) < 2 {
-			continue
-		}
-		xTag := tag.TypeParams[0].StructTag
-		yTag := tag.TypeParams[1].StructTag
-		if nil == xTag || nil == yTag {
-			continue
-		}
-		xCoinInfo, bx := p.coinListClient.GetCoinInfoByType(xTag)
-		yCoinInfo, by := p.coinListClient.GetCoinInfoByType(yTag)
-		if !bx || !by {
-			continue
-		}
-		pool := NewObricTradingPool(xCoinInfo, yCoinInfo, resource)
-		if pool == nil {
-			// todo handle error
-			continue
-		}
-
-		poolList = append(poolList, pool)
-	}
-
-	return poolList
+	return base.NewPoolProvider(client, ownerAddress, coinListClient, base.PoolSpec{
+		ResourceMatcher: base.ContainsMatcher("piece_swap::PieceSwapPoolInfo"),
+		CoinTypeParams:  [2]int{0, 1},
+		ResourceTypes: func(x, y string) []string {
+			return []string{fmt.Sprintf("%s::piece_swap::PieceSwapPoolInfo<%s, %s>", ownerAddress, x, y)}
+		},
+		Decode: func(ctx base.PoolContext, resource aptostypes.AccountResource) (base.TradingPool, error) {
+			return NewObricTradingPool(ctx.OwnerAddress, ctx.XCoinInfo, ctx.YCoinInfo, resource)
+		},
+	})
 }
@@ -0,0 +1,44 @@
+package obric
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/coming-chat/go-aptos/aptostypes"
+)
+
+func TestNewPieceSwapPoolInfo_MissingField(t *testing.T) {
+	coin := func(value string) map[string]interface{} {
+		return map[string]interface{}{"value": value}
+	}
+	data := map[string]interface{}{
+		"K":                               "100000000000000",
+		"K2":                              "1000000000000",
+		"Xa":                              "9990000000",
+		"Xb":                              "10010000000",
+		"m":                               "1000000000",
+		"n":                               "1000000000",
+		"protocol_fee_share_per_thousand": "300",
+		"swap_fee_per_million":            "100",
+		"x_deci_mult":                     "1",
+		"y_deci_mult":                     "1",
+		"reserve_x":                       coin("10000000000"),
+		"reserve_y":                       coin("10000000000"),
+		"protocol_fee_x":                  coin("0"),
+		"protocol_fee_y":                  coin("0"),
+	}
+	resource := aptostypes.AccountResource{
+		Type: "0x1::piece_swap::PieceSwapPoolInfo<0x1::a::A, 0x1::b::B>",
+		Data: data,
+	}
+	if _, err := NewPieceSwapPoolInfo(resource); err != nil {
+		t.Fatalf("NewPieceSwapPoolInfo() error = %v", err)
+	}
+
+	delete(data, "K")
+	delete(data, "m")
+	_, err := NewPieceSwapPoolInfo(resource)
+	if err == nil || !strings.Contains(err.Error(), "missing fields: K, m") {
+		t.Errorf("NewPieceSwapPoolInfo() error = %v, want missing fields: K, m", err)
+	}
+}
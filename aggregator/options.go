@@ -0,0 +1,83 @@
+package aggregator
+
+import (
+	"github.com/omnibtc/go-hippo-sdk/aggregator/base"
+	"github.com/omnibtc/go-hippo-sdk/types"
+)
+
+// DefaultMaxSteps is used when RouteOptions.MaxSteps is not set
+const DefaultMaxSteps = 3
+
+// MaxRouteSteps is the most steps a route payload supports
+const MaxRouteSteps = 3
+
+// RouteOptions restrict the routes searched by GetAllRoutes, GetQuotes and GetBestQuote,
+// the zero value searches every pool with up to DefaultMaxSteps steps
+type RouteOptions struct {
+	// MaxSteps is the max number of steps of a route, 1 to 3
+	MaxSteps int
+	// AllowRoundTrip allow routes which pass the same coin twice
+	AllowRoundTrip bool
+	// AllowedDexes only use pools of these dexes if not empty
+	AllowedDexes []base.DexType
+	// DeniedDexes never use pools of these dexes
+	DeniedDexes []base.DexType
+	// AllowedIntermediates only route through these coins if not empty
+	AllowedIntermediates []types.TokenType
+	// PoolFilters all filters must accept a pool to use it
+	PoolFilters []PoolFilter
+	// TopK only extend the K partial paths with most output at each intermediate coin when quoting, 0 is unlimited.
+	// Pruned search never returns round trip routes.
+	TopK int
+	// AllowUnverified route through coins marked unverified, the input and output coins may always be unverified
+	AllowUnverified bool
+}
+
+func (o RouteOptions) getMaxSteps() int {
+	if o.MaxSteps <= 0 {
+		return DefaultMaxSteps
+	}
+	if o.MaxSteps > MaxRouteSteps {
+		return MaxRouteSteps
+	}
+	return o.MaxSteps
+}
+
+// poolFilters merge the dex options and pool filters
+func (o RouteOptions) poolFilters() []PoolFilter {
+	filters := make([]PoolFilter, 0, len(o.PoolFilters)+2)
+	if len(o.AllowedDexes) > 0 {
+		filters = append(filters, IncludeDexes(o.AllowedDexes...))
+	}
+	if len(o.DeniedDexes) > 0 {
+		filters = append(filters, ExcludeDexes(o.DeniedDexes...))
+	}
+	return append(filters, o.PoolFilters...)
+}
+
+// filterIntermediates return the coins of list which can be used as intermediate
+func (o RouteOptions) filterIntermediates(list []types.CoinInfo) []types.CoinInfo {
+	if !o.AllowUnverified {
+		verified := make([]types.CoinInfo, 0, len(list))
+		for _, coin := range list {
+			if !coin.Unverified {
+				verified = append(verified, coin)
+			}
+		}
+		list = verified
+	}
+	if len(o.AllowedIntermediates) == 0 {
+		return list
+	}
+	allowed := make(map[string]struct{}, len(o.AllowedIntermediates))
+	for _, t := range o.AllowedIntermediates {
+		allowed[t.GetFullName()] = struct{}{}
+	}
+	result := make([]types.CoinInfo, 0, len(o.AllowedIntermediates))
+	for _, coin := range list {
+		if _, ok := allowed[coin.TokenType.GetFullName()]; ok {
+			result = append(result, coin)
+		}
+	}
+	return result
+}
@@ -0,0 +1,261 @@
+package aggregator
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+
+	"github.com/omnibtc/go-hippo-sdk/aggregator/base"
+	"github.com/omnibtc/go-hippo-sdk/types"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	// DefaultMaxDeviationBps is how far a route price may be from the median before it is rejected
+	DefaultMaxDeviationBps = 500
+	// oracleDepthImpactBps is the price impact used to measure the depth of a route
+	oracleDepthImpactBps = 100
+	// oraclePrecision is the decimal places of prices
+	oraclePrecision = 18
+)
+
+// CoinPrice is the value of one whole coin in whole reference coin
+type CoinPrice struct {
+	Coin  types.CoinInfo
+	Price decimal.Decimal
+	// Confidence is from 0 to 1, the share of route depth agreeing with the price scaled by how deep the routes are
+	Confidence float64
+	// Sources is the number of routes the price is averaged from
+	Sources int
+	// Outliers is the number of routes rejected for deviating from the median
+	Outliers int
+	// Depth is the value in reference coin which the agreeing routes can swap within 1% price impact
+	Depth decimal.Decimal
+}
+
+// PriceOracle value coins in a reference coin from the mid price of routes over the loaded pools,
+// it never queries the chain so it works on a snapshot of pools.
+type PriceOracle struct {
+	aggregator *TradeAggregator
+	reference  types.CoinInfo
+	// MaxDeviationBps reject routes with mid price further than this from the weighted median
+	MaxDeviationBps uint64
+	// FullConfidenceDepth is the depth in whole reference coin from which depth does not lower confidence
+	FullConfidenceDepth decimal.Decimal
+
+	lock            sync.Mutex
+	registry        *PoolRegistry
+	coinListVersion uint64
+	prices          map[string]*CoinPrice
+}
+
+func NewPriceOracle(aggregator *TradeAggregator, reference types.CoinInfo) *PriceOracle {
+	return &PriceOracle{
+		aggregator:          aggregator,
+		reference:           reference,
+		MaxDeviationBps:     DefaultMaxDeviationBps,
+		FullConfidenceDepth: decimal.NewFromInt(100000),
+		prices:              make(map[string]*CoinPrice),
+	}
+}
+
+// GetPrice value one whole coin in reference coin, prices are cached until the pools or the coin list are reloaded
+func (o *PriceOracle) GetPrice(coin types.CoinInfo) (*CoinPrice, error) {
+	fullName := coin.TokenType.GetFullName()
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	registry, version := o.aggregator.Registry(), o.aggregator.coinListClient.Version()
+	if o.registry != registry || o.coinListVersion != version {
+		o.registry = registry
+		o.coinListVersion = version
+		o.prices = make(map[string]*CoinPrice)
+	}
+	if price, ok := o.prices[fullName]; ok {
+		return price, nil
+	}
+	price, err := o.getPrice(coin)
+	if err != nil {
+		return nil, err
+	}
+	o.prices[fullName] = price
+	return price, nil
+}
+
+// Value implement CoinValuer, amount is in base units of coin
+func (o *PriceOracle) Value(coin types.CoinInfo, amount *big.Int) (decimal.Decimal, bool) {
+	price, err := o.GetPrice(coin)
+	if err != nil {
+		return decimal.Zero, false
+	}
+	return price.Price.Mul(coin.FromBaseUnits(amount)), true
+}
+
+// PoolTVL value the reserves of pool in reference coin.
+// If only one side can be valued, the pool is assumed to hold equal value of both sides.
+func (o *PriceOracle) PoolTVL(pool base.TradingPool) (decimal.Decimal, error) {
+	reserveX, reserveY := pool.Reserves()
+	if reserveX == nil || reserveY == nil {
+		return decimal.Zero, fmt.Errorf("pool %s has no reserves", pool.PoolId())
+	}
+	valueX, okX := o.Value(pool.XCoinInfo(), reserveX)
+	valueY, okY := o.Value(pool.YCoinInfo(), reserveY)
+	switch {
+	case okX && okY:
+		return valueX.Add(valueY), nil
+	case okX:
+		return valueX.Mul(decimal.NewFromInt(2)), nil
+	case okY:
+		return valueY.Mul(decimal.NewFromInt(2)), nil
+	default:
+		return decimal.Zero, fmt.Errorf("can not value pool %s", pool.PoolId())
+	}
+}
+
+// routePrice is the mid price of a route and how much it can swap
+type routePrice struct {
+	price decimal.Decimal
+	depth decimal.Decimal
+}
+
+func (o *PriceOracle) getPrice(coin types.CoinInfo) (*CoinPrice, error) {
+	if coin.TokenType.GetFullName() == o.reference.TokenType.GetFullName() {
+		return &CoinPrice{Coin: coin, Price: decimal.NewFromInt(1), Confidence: 1, Sources: 1, Depth: o.FullConfidenceDepth}, nil
+	}
+	// search without the liquidity filter, which may value coins with this oracle
+	routes := o.aggregator.getOneStepRoutes(coin, o.reference, nil)
+	fullList, err := o.aggregator.coinList()
+	if err != nil {
+		return nil, err
+	}
+	routes = append(routes, o.aggregator.getTwoStepRoutes(coin, o.reference, RouteOptions{}.filterIntermediates(fullList), nil)...)
+
+	// base units of reference per base unit of coin to whole units
+	scale := decimal.New(1, int32(coin.Decimals-o.reference.Decimals))
+	prices := make([]routePrice, 0, len(routes))
+	for _, route := range routes {
+		mid, ok := routeMidPrice(route)
+		if !ok {
+			continue
+		}
+		price := ratToDecimal(mid).Mul(scale)
+		depth := decimal.Zero
+		if sizing, err := OptimizeInput(route, MaxInputWithinImpact(oracleDepthImpactBps), nil); err == nil {
+			depth = price.Mul(coin.FromBaseUnits(sizing.InputAmount))
+		}
+		prices = append(prices, routePrice{price: price, depth: depth})
+	}
+	if len(prices) == 0 {
+		return nil, fmt.Errorf("no route from %s to %s", coin.Symbol, o.reference.Symbol)
+	}
+
+	median := weightedMedian(prices)
+	maxDeviation := median.Mul(decimal.New(int64(o.MaxDeviationBps), -4))
+	result := &CoinPrice{Coin: coin}
+	totalDepth, keptDepth, weighted := decimal.Zero, decimal.Zero, decimal.Zero
+	for _, p := range prices {
+		totalDepth = totalDepth.Add(p.depth)
+		if p.price.Sub(median).Abs().GreaterThan(maxDeviation) {
+			result.Outliers++
+			continue
+		}
+		result.Sources++
+		keptDepth = keptDepth.Add(p.depth)
+		weighted = weighted.Add(p.price.Mul(p.depth))
+	}
+	if keptDepth.IsPositive() {
+		result.Price = weighted.DivRound(keptDepth, oraclePrecision)
+	} else {
+		result.Price = median
+	}
+	result.Depth = keptDepth
+	result.Confidence = o.confidence(keptDepth, totalDepth)
+	return result, nil
+}
+
+// confidence is the share of depth agreeing with the price, scaled down if the agreeing depth is under FullConfidenceDepth
+func (o *PriceOracle) confidence(keptDepth, totalDepth decimal.Decimal) float64 {
+	if !totalDepth.IsPositive() {
+		return 0
+	}
+	agreement, _ := keptDepth.Div(totalDepth).Float64()
+	depthScore := 1.0
+	if o.FullConfidenceDepth.IsPositive() && keptDepth.LessThan(o.FullConfidenceDepth) {
+		depthScore, _ = keptDepth.Div(o.FullConfidenceDepth).Float64()
+	}
+	return agreement * depthScore
+}
+
+// weightedMedian return the price at half of the total depth, plain median if no route has depth
+func weightedMedian(prices []routePrice) decimal.Decimal {
+	sorted := append([]routePrice{}, prices...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].price.LessThan(sorted[j].price)
+	})
+	total := decimal.Zero
+	for _, p := range sorted {
+		total = total.Add(p.depth)
+	}
+	if !total.IsPositive() {
+		return sorted[len(sorted)/2].price
+	}
+	half := total.Div(decimal.NewFromInt(2))
+	acc := decimal.Zero
+	for _, p := range sorted {
+		acc = acc.Add(p.depth)
+		if acc.GreaterThanOrEqual(half) {
+			return p.price
+		}
+	}
+	return sorted[len(sorted)-1].price
+}
+
+// routeMidPrice multiply the mid price of each step
+func routeMidPrice(route base.TradeRoute) (*big.Rat, bool) {
+	price := big.NewRat(1, 1)
+	for _, step := range route.Steps {
+		mid, ok := stepMidPrice(step)
+		if !ok {
+			return nil, false
+		}
+		price.Mul(price, mid)
+	}
+	return price, true
+}
+
+// stepMidPrice return the fee free marginal price in base units,
+// constant product pools are priced by their reserve ratio, other pools by probing:
+// the fee cancels out in sqrt(forward spot / backward spot) as forward = p*(1-fee) and backward = (1-fee)/p
+func stepMidPrice(step base.TradeStep) (*big.Rat, bool) {
+	if cp, ok := step.Pool.(base.ConstantProductPool); ok {
+		if params, ok := cp.ConstantProductParams(step.IsXtoY); ok {
+			if params.ReserveIn == nil || params.ReserveOut == nil || params.ReserveIn.Sign() <= 0 || params.ReserveOut.Sign() <= 0 {
+				return nil, false
+			}
+			return new(big.Rat).SetFrac(params.ReserveOut, params.ReserveIn), true
+		}
+	}
+	reserveX, reserveY := step.Pool.Reserves()
+	if reserveX == nil || reserveY == nil || reserveX.Sign() <= 0 || reserveY.Sign() <= 0 {
+		return nil, false
+	}
+	reserveIn, reserveOut := reserveX, reserveY
+	if !step.IsXtoY {
+		reserveIn, reserveOut = reserveOut, reserveIn
+	}
+	forward, err := spotRate(base.NewTradeRoute([]base.TradeStep{step}), reserveIn)
+	if err != nil {
+		return nil, false
+	}
+	backward, err := spotRate(base.NewTradeRoute([]base.TradeStep{base.NewTradeStep(step.Pool, !step.IsXtoY)}), reserveOut)
+	if err != nil {
+		return nil, false
+	}
+	square := new(big.Float).SetPrec(256).SetRat(new(big.Rat).Quo(forward, backward))
+	mid, _ := new(big.Float).SetPrec(256).Sqrt(square).Rat(nil)
+	return mid, true
+}
+
+func ratToDecimal(r *big.Rat) decimal.Decimal {
+	return decimal.NewFromBigInt(r.Num(), 0).DivRound(decimal.NewFromBigInt(r.Denom(), 0), oraclePrecision)
+}
@@ -8,7 +8,6 @@ import (
 	"github.com/omnibtc/go-hippo-sdk/aggregator/coinlist"
 	"github.com/omnibtc/go-hippo-sdk/types"
 	"math/big"
-	"strings"
 )
 
 type Pool struct {
@@ -17,28 +16,34 @@ type Pool struct {
 	blockTimestampLast *big.Int
 }
 
-func NewPool(resource aptostypes.AccountResource) *Pool {
-	data := resource.Data
-	blockTimestampLast, b := big.NewInt(0).SetString(data["block_timestamp_last"].(string), 10)
-	if !b {
-		return nil
-	}
-	reserveX, b := big.NewInt(0).SetString(data["reserve_x"].(string), 10)
-	if !b {
-		return nil
-	}
-	reserveY, b := big.NewInt(0).SetString(data["reserve_y"].(string), 10)
-	if !b {
-		return nil
+// tokenPairReserveResource is the json layout of pancake swap::TokenPairReserve
+type tokenPairReserveResource struct {
+	ReserveX           types.Uint `json:"reserve_x"`
+	ReserveY           types.Uint `json:"reserve_y"`
+	BlockTimestampLast types.Uint `json:"block_timestamp_last"`
+}
+
+func (r tokenPairReserveResource) Validate() error {
+	return types.RequireFields(map[string]types.Field{
+		"reserve_x":            r.ReserveX,
+		"reserve_y":            r.ReserveY,
+		"block_timestamp_last": r.BlockTimestampLast,
+	})
+}
+
+func NewPool(resource aptostypes.AccountResource) (*Pool, error) {
+	var data tokenPairReserveResource
+	if err := base.DecodeResource(resource, &data); err != nil {
+		return nil, err
 	}
-	if reserveX.Cmp(big.NewInt(0)) == 0 || reserveY.Cmp(big.NewInt(0)) == 0 {
-		return nil
+	if data.ReserveX.BigInt().Sign() == 0 || data.ReserveY.BigInt().Sign() == 0 {
+		return nil, base.ErrEmptyPool
 	}
 	return &Pool{
-		reserveX:           reserveX,
-		reserveY:           reserveY,
-		blockTimestampLast: blockTimestampLast,
-	}
+		reserveX:           data.ReserveX.BigInt(),
+		reserveY:           data.ReserveY.BigInt(),
+		blockTimestampLast: data.BlockTimestampLast.BigInt(),
+	}, nil
 }
 
 func (p *Pool) tokenReserves() (reserveX, reserveY, blockTimestampLast *big.Int) {
@@ -46,25 +51,35 @@ func (p *Pool) tokenReserves() (reserveX, reserveY, blockTimestampLast *big.Int)
 }
 
 type TradingPool struct {
-	pool          *Pool
-	xCoinInfo     types.CoinInfo
-	yCoinInfo     types.CoinInfo
-	owner         string
-	scriptAddress string
+	poolId       base.PoolId
+	pool         *Pool
+	xCoinInfo    types.CoinInfo
+	yCoinInfo    types.CoinInfo
+	owner        string
+	swapFunction types.FunctionId
 }
 
-func NewTradingPool(xCoinInfo, yCoinInfo types.CoinInfo, owner string, resource aptostypes.AccountResource, scriptAddress string) base.TradingPool {
-	pool := NewPool(resource)
-	if pool == nil {
-		return nil
+func NewTradingPool(xCoinInfo, yCoinInfo types.CoinInfo, owner string, resource aptostypes.AccountResource, swapFunction types.FunctionId) (base.TradingPool, error) {
+	tag, err := types.ParseMoveStructTag(resource.Type)
+	if err != nil {
+		return nil, err
 	}
-	return &TradingPool{
-		pool:          pool,
-		xCoinInfo:     xCoinInfo,
-		yCoinInfo:     yCoinInfo,
-		owner:         owner,
-		scriptAddress: scriptAddress,
+	pool, err := NewPool(resource)
+	if err != nil {
+		return nil, err
 	}
+	return &TradingPool{
+		poolId:       base.NewPoolId(base.Pancake, owner, tag),
+		pool:         pool,
+		xCoinInfo:    xCoinInfo,
+		yCoinInfo:    yCoinInfo,
+		owner:        owner,
+		swapFunction: swapFunction,
+	}, nil
+}
+
+func (t *TradingPool) PoolId() base.PoolId {
+	return t.poolId
 }
 
 func (t *TradingPool) DexType() base.DexType {
@@ -91,11 +106,24 @@ func (t *TradingPool) IsStateLoaded() bool {
 	return t.pool != nil
 }
 
+func (t *TradingPool) Reserves() (reserveX, reserveY *big.Int) {
+	return t.pool.reserveX, t.pool.reserveY
+}
+
 // ReloadState() error
 func (t *TradingPool) GetPrice() base.PriceType {
 	panic("not implemented")
 }
 
+func (t *TradingPool) ConstantProductParams(isXToY bool) (base.ConstantProductParams, bool) {
+	return base.NewConstantProductParams(t.pool.reserveX, t.pool.reserveY, isXToY, 25), true
+}
+
+func (t *TradingPool) ReservesTimestamp() uint64 {
+	_, _, blockTimestampLast := t.pool.tokenReserves()
+	return blockTimestampLast.Uint64()
+}
+
 func (t *TradingPool) GetQuote(inputAmount base.TokenAmount, isXToY bool) base.QuoteType {
 	if !t.IsStateLoaded() {
 		panic("pancake pool not loaded")
@@ -134,7 +162,7 @@ func (t *TradingPool) MakePayload(input base.TokenAmount, minOut base.TokenAmoun
 	typeArgs := make([]string, 0)
 	typeArgs = append(typeArgs, xTokenType.GetFullName(), yTokenType.GetFullName())
 	return types.EntryFunctionPayload{
-		Function: fmt.Sprintf("%s::%s::%s", t.scriptAddress, "router", "swap_exact_input"),
+		Function: t.swapFunction,
 		TypeArgs: typeArgs,
 		Args: []interface{}{
 			inputAmount,
@@ -161,73 +189,19 @@ func getAmountOut(amountIn, reserveIn, reserveOut *big.Int) *big.Int {
 	return amountOut
 }
 
-type PancakePoolProvider struct {
-	client         *aptosclient.RestClient
-	ownerAddress   string
-	coinListClient *coinlist.CoinListClient
-	resourceTypes  []string
-	scriptAddress  string
-}
-
-func NewPoolProvider(client *aptosclient.RestClient, ownerAddress string, coinListClient *coinlist.CoinListClient, scriptAddress string) base.TradingPoolProvider {
-	return &PancakePoolProvider{
-		client:         client,
-		ownerAddress:   ownerAddress,
-		coinListClient: coinListClient,
-		scriptAddress:  scriptAddress,
-	}
-}
-
-func (p *PancakePoolProvider) SetResourceTypes(resourceTypes []string) {
-	if len(resourceTypes) == 0 {
-		return
-	}
-	p.resourceTypes = resourceTypes
-}
-
-func (p *PancakePoolProvider) LoadPoolList() []base.TradingPool {
-	poolList := make([]base.TradingPool, 0)
-	resources, err := p.client.GetAccountResources(p.ownerAddress, 0)
+func NewPoolProvider(client *aptosclient.RestClient, ownerAddress string, coinListClient *coinlist.CoinListClient, scriptAddress string) (base.TradingPoolProvider, error) {
+	swapFunction, err := types.NewFunctionId(scriptAddress, "router", "swap_exact_input")
 	if err != nil {
-		for _, resourceType := range p.resourceTypes {
-			resource, err := p.client.GetAccountResource(p.ownerAddress, resourceType, 0)
-			if err != nil {
-				continue
-			}
-			resources = append(resources, *resource)
-		}
-	}
-
-	for _, resource := range resources {
-		if !strings.Contains(resource.Type, "swap::TokenPairReserve") {
-			continue
-		}
-		tag, err := types.ParseMoveStructTag(resource.Type)
-		if err != nil {
-			// todo handle error
-			continue
-		}
-		if len(tag.TypeParams) < 2 {
-			continue
-		}
-		xTag := tag.TypeParams[0].StructTag
-		yTag := tag.TypeParams[1].StructTag
-		if nil == xTag || nil == yTag {
-			continue
-		}
-		xCoinInfo, bx := p.coinListClient.GetCoinInfoByType(xTag)
-		yCoinInfo, by := p.coinListClient.GetCoinInfoByType(yTag)
-		if !bx || !by {
-			continue
-		}
-		pool := NewTradingPool(xCoinInfo, yCoinInfo, p.ownerAddress, resource, p.scriptAddress)
-		if pool == nil {
-			// todo handle error
-			continue
-		}
-
-		poolList = append(poolList, pool)
+		return nil, err
 	}
-
-	return poolList
+	return base.NewPoolProvider(client, ownerAddress, coinListClient, base.PoolSpec{
+		ResourceMatcher: base.ContainsMatcher("swap::TokenPairReserve"),
+		CoinTypeParams:  [2]int{0, 1},
+		ResourceTypes: func(x, y string) []string {
+			return []string{fmt.Sprintf("%s::swap::TokenPairReserve<%s, %s>", ownerAddress, x, y)}
+		},
+		Decode: func(ctx base.PoolContext, resource aptostypes.AccountResource) (base.TradingPool, error) {
+			return NewTradingPool(ctx.XCoinInfo, ctx.YCoinInfo, ctx.OwnerAddress, resource, swapFunction)
+		},
+	}), nil
 }
@@ -1,55 +1,64 @@
 package pontem
 
 import (
+	"errors"
 	"fmt"
 	"math/big"
 	"strings"
 
 	"github.com/coming-chat/go-aptos/aptosclient"
+	"github.com/coming-chat/go-aptos/aptostypes"
 	"github.com/omnibtc/go-aptos-liquidswap/liquidswap"
 	"github.com/omnibtc/go-hippo-sdk/aggregator/base"
 	"github.com/omnibtc/go-hippo-sdk/aggregator/coinlist"
 	"github.com/omnibtc/go-hippo-sdk/types"
 )
 
+// ModuleAddress is the address Liquidswap modules are published at on mainnet
+const ModuleAddress = "0x190d44266241744264b964a37b8f09863167a12d3e70cda39376cfb4e3561e12"
+
+// uncorrelatedCurve is the only liquidswap curve quoted, the third type param of LiquidityPool
+const uncorrelatedCurve = "Uncorrelated"
+
 type RawPontemPool struct {
 	CoinXReserve *big.Int
 	CoinYReserve *big.Int
 }
 
+// poolResource is the json layout of liquidswap liquidity_pool::LiquidityPool
+type poolResource struct {
+	CoinXReserve types.Coin `json:"coin_x_reserve"`
+	CoinYReserve types.Coin `json:"coin_y_reserve"`
+}
+
+func (r poolResource) Validate() error {
+	return types.RequireFields(map[string]types.Field{
+		"coin_x_reserve": r.CoinXReserve,
+		"coin_y_reserve": r.CoinYReserve,
+	})
+}
+
 type TradingPool struct {
+	poolId          base.PoolId
 	pontemPool      RawPontemPool
 	xCoinInfo       types.CoinInfo
 	yCoinInfo       types.CoinInfo
 	ownerAddress    string
 	lpTag           types.StructTag
 	poolResourceTag string
-	scriptAddress   string
-}
-
-type PoolProvider struct {
-	client         *aptosclient.RestClient
-	ownerAddress   string
-	coinListClient *coinlist.CoinListClient
-	resourceTypes  []string
-	scriptAddress  string
+	swapFunction    types.FunctionId
 }
 
 func NewTradingPool() base.TradingPool {
 	return &TradingPool{}
 }
 
-func NewPoolProvider(client *aptosclient.RestClient, ownerAddress string, coinListClient *coinlist.CoinListClient, scriptAddress string) base.TradingPoolProvider {
-	return &PoolProvider{
-		client:         client,
-		ownerAddress:   ownerAddress,
-		coinListClient: coinListClient,
-		scriptAddress:  scriptAddress,
-	}
-}
-
 /** implement base.TradingPool */
 
+func (t *TradingPool) PoolId() base.PoolId {
+	return t.poolId
+}
+
 func (t *TradingPool) DexType() base.DexType {
 	return base.Pontem
 }
@@ -74,6 +83,10 @@ func (t *TradingPool) IsStateLoaded() bool {
 	return true
 }
 
+func (t *TradingPool) Reserves() (reserveX, reserveY *big.Int) {
+	return t.pontemPool.CoinXReserve, t.pontemPool.CoinYReserve
+}
+
 func (t *TradingPool) GetTagE() types.TokenType {
 	return &t.lpTag
 }
@@ -113,7 +126,7 @@ func (t *TradingPool) GetQuote(inputAmount base.TokenAmount, isXToY bool) base.Q
 		Symbol:   outputTokenInfo.Symbol,
 		Name:     outputTokenInfo.Name,
 	}
-	if t.lpTag.Name == "Uncorrelated" {
+	if t.lpTag.Name == uncorrelatedCurve {
 		pool.CurveType = liquidswap.Uncorellated
 	} else {
 		pool.CurveType = liquidswap.StableCurve
@@ -143,9 +156,9 @@ func (t *TradingPool) MakePayload(input base.TokenAmount, minOut base.TokenAmoun
 
 	inputAmount, outAmount := base.BigIntToUint64(input, minOut)
 	typeArgs := make([]string, 0)
-	typeArgs = append(typeArgs, xTokenType.GetFullName(), yTokenType.GetFullName(), fmt.Sprintf("%s::curves::Uncorrelated", t.scriptAddress))
+	typeArgs = append(typeArgs, xTokenType.GetFullName(), yTokenType.GetFullName(), fmt.Sprintf("%s::curves::%s", t.swapFunction.Module.Address, uncorrelatedCurve))
 	return types.EntryFunctionPayload{
-		Function: fmt.Sprintf("%s::%s::%s", t.scriptAddress, "scripts_v2", "swap"),
+		Function: t.swapFunction,
 		TypeArgs: typeArgs,
 		Args: []interface{}{
 			inputAmount,
@@ -154,82 +167,50 @@ func (t *TradingPool) MakePayload(input base.TokenAmount, minOut base.TokenAmoun
 	}
 }
 
-/** implement base.TradingPoolProvider */
-
-func (p *PoolProvider) SetResourceTypes(resourceTypes []string) {
-	if len(resourceTypes) == 0 {
-		return
+func NewPoolProvider(client *aptosclient.RestClient, ownerAddress string, coinListClient *coinlist.CoinListClient, scriptAddress string) (base.TradingPoolProvider, error) {
+	swapFunction, err := types.NewFunctionId(scriptAddress, "scripts_v2", "swap")
+	if err != nil {
+		return nil, err
 	}
-	p.resourceTypes = resourceTypes
-}
+	return base.NewPoolProvider(client, ownerAddress, coinListClient, base.PoolSpec{
+		// stable curve pools are skipped, their quote math is not implemented
+		ResourceMatcher: func(resourceType string) bool {
+			return strings.Contains(resourceType, "liquidity_pool::LiquidityPool") &&
+				strings.HasSuffix(resourceType, "::curves::"+uncorrelatedCurve+">")
+		},
+		CoinTypeParams: [2]int{0, 1},
+		ResourceTypes: func(x, y string) []string {
+			return []string{fmt.Sprintf("%s::liquidity_pool::LiquidityPool<%s, %s, %s::curves::%s>", ModuleAddress, x, y, ModuleAddress, uncorrelatedCurve)}
+		},
+		Decode: func(ctx base.PoolContext, resource aptostypes.AccountResource) (base.TradingPool, error) {
+			if len(ctx.Tag.TypeParams) < 3 || ctx.Tag.TypeParams[2].StructTag == nil {
+				return nil, errors.New("missing pontem curve type param")
+			}
+			lpTag := ctx.Tag.TypeParams[2].StructTag
+			if lpTag.Module != "curves" || lpTag.Name != uncorrelatedCurve {
+				return nil, fmt.Errorf("unsupported pontem curve %s", lpTag.GetFullName())
+			}
 
-func (p *PoolProvider) LoadPoolList() []base.TradingPool {
-	poolList := make([]base.TradingPool, 0)
-	resources, err := p.client.GetAccountResources(p.ownerAddress, 0)
-	if err != nil {
-		for _, resourceType := range p.resourceTypes {
-			resource, err := p.client.GetAccountResource(p.ownerAddress, resourceType, 0)
-			if err != nil {
-				continue
+			var data poolResource
+			if err := base.DecodeResource(resource, &data); err != nil {
+				return nil, err
+			}
+			if data.CoinXReserve.Value.Sign() == 0 || data.CoinYReserve.Value.Sign() == 0 {
+				return nil, base.ErrEmptyPool
 			}
-			resources = append(resources, *resource)
-		}
-	}
-	for _, resource := range resources {
-		if !strings.Contains(resource.Type, "liquidity_pool::LiquidityPool") {
-			continue
-		}
-		tag, err := types.ParseMoveStructTag(resource.Type)
-		if err != nil {
-			// todo handle error
-			continue
-		}
-		if len(tag.TypeParams) < 3 {
-			continue
-		}
-		xTag := tag.TypeParams[0].StructTag
-		yTag := tag.TypeParams[1].StructTag
-		lpTag := tag.TypeParams[2].StructTag
-		if nil == xTag || nil == yTag || nil == lpTag {
-			continue
-		}
-
-		xCoinInfo, bx := p.coinListClient.GetCoinInfoByType(xTag)
-		yCoinInfo, by := p.coinListClient.GetCoinInfoByType(yTag)
-		if !bx || !by {
-			continue
-		}
-
-		x := resource.Data["coin_x_reserve"].(map[string]interface{})["value"].(string)
-		y := resource.Data["coin_y_reserve"].(map[string]interface{})["value"].(string)
-		if x == "0" || y == "0" {
-			continue
-		}
-		xint, b := big.NewInt(0).SetString(x, 10)
-		if !b {
-			continue
-		}
-		yint, b := big.NewInt(0).SetString(y, 10)
-		if !b {
-			continue
-		}
-
-		if lpTag.Name != "Uncorrelated" {
-			continue
-		}
-
-		poolList = append(poolList, &TradingPool{
-			pontemPool: RawPontemPool{
-				CoinXReserve: xint,
-				CoinYReserve: yint,
-			},
-			xCoinInfo:     xCoinInfo,
-			yCoinInfo:     yCoinInfo,
-			ownerAddress:  p.ownerAddress,
-			lpTag:         *lpTag,
-			scriptAddress: p.scriptAddress,
-		})
-	}
 
-	return poolList
+			return &TradingPool{
+				poolId: base.NewPoolId(base.Pontem, ctx.OwnerAddress, ctx.Tag),
+				pontemPool: RawPontemPool{
+					CoinXReserve: data.CoinXReserve.Value,
+					CoinYReserve: data.CoinYReserve.Value,
+				},
+				xCoinInfo:    ctx.XCoinInfo,
+				yCoinInfo:    ctx.YCoinInfo,
+				ownerAddress: ctx.OwnerAddress,
+				lpTag:        *lpTag,
+				swapFunction: swapFunction,
+			}, nil
+		},
+	}), nil
 }
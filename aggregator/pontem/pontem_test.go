@@ -1,56 +1,69 @@
 package pontem
 
 import (
-	"context"
+	"encoding/json"
+	"math/big"
+	"os"
 	"testing"
 
-	"github.com/coming-chat/go-aptos/aptosclient"
+	"github.com/coming-chat/go-aptos/aptostypes"
+	"github.com/omnibtc/go-hippo-sdk/aggregator/base"
 	"github.com/omnibtc/go-hippo-sdk/aggregator/coinlist"
 	"github.com/omnibtc/go-hippo-sdk/contract"
 )
 
-const TestNode = "https://fullnode.testnet.aptoslabs.com"
+// PoolAddress is the resource account holding liquidswap pools on mainnet
+const PoolAddress = "0x05a97986a9d031c4567e15b797be516910cfcb4156312482efc6a19c0a30c948"
 
 func TestPoolProvider_LoadPoolList(t *testing.T) {
-	client, err := aptosclient.Dial(context.Background(), TestNode)
+	data, err := os.ReadFile("testdata/resources.json")
 	if err != nil {
-		panic(err)
+		t.Fatal(err)
+	}
+	var resources []aptostypes.AccountResource
+	if err = json.Unmarshal(data, &resources); err != nil {
+		t.Fatal(err)
 	}
-
 	coinListClient, err := coinlist.LoadCoinListClient(contract.App{
 		CoinList: contract.NewDevCoinListApp(),
 	})
 	if err != nil {
-		panic(err)
-	}
-	type fields struct {
-		client         *aptosclient.RestClient
-		ownerAddress   string
-		coinlistClient *coinlist.CoinListClient
-	}
-	tests := []struct {
-		name   string
-		fields fields
-	}{
-		{
-			name: "test",
-			fields: fields{
-				client:         client,
-				ownerAddress:   "0x385068db10693e06512ed54b1e6e8f1fb9945bb7a78c28a45585939ce953f99e",
-				coinlistClient: coinListClient,
-			},
-		},
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			p := &PoolProvider{
-				client:         tt.fields.client,
-				ownerAddress:   tt.fields.ownerAddress,
-				coinListClient: tt.fields.coinlistClient,
-			}
-			if got := p.LoadPoolList(); len(got) == 0 {
-				t.Errorf("Pontem PoolProvider.LoadPoolList() Empty")
-			}
-		})
+		t.Fatal(err)
+	}
+
+	if _, err = NewPoolProvider(nil, PoolAddress, coinListClient, "not an address"); err == nil {
+		t.Error("NewPoolProvider() with a bad script address, want error")
+	}
+	poolProvider, err := NewPoolProvider(nil, PoolAddress, coinListClient, ModuleAddress)
+	if err != nil {
+		t.Fatal(err)
+	}
+	provider := poolProvider.(*base.PoolProvider)
+	provider.SetResourceLoader(base.StaticResourceLoader(resources))
+	pools, err := provider.LoadPools()
+	// the stable pool is skipped
+	if len(pools) != 1 {
+		t.Fatalf("Pontem PoolProvider.LoadPools() = %d pools, want 1", len(pools))
+	}
+	// the empty pool is skipped without error
+	if err != nil {
+		t.Errorf("Pontem PoolProvider.LoadPools() error = %v", err)
+	}
+
+	for _, pool := range pools {
+		if pool.GetTagE().GetFullName() != ModuleAddress+"::curves::Uncorrelated" {
+			t.Errorf("Pontem PoolProvider.LoadPools() curve = %s", pool.GetTagE().GetFullName())
+		}
+		payload := pool.(*TradingPool).MakePayload(pool.XCoinInfo().Unit(), big.NewInt(0), true)
+		if payload.Function.String() != ModuleAddress+"::scripts_v2::swap" {
+			t.Errorf("MakePayload() function = %s", payload.Function)
+		}
+		if payload.TypeArgs[2] != pool.GetTagE().GetFullName() {
+			t.Errorf("MakePayload() curve = %s, want %s", payload.TypeArgs[2], pool.GetTagE().GetFullName())
+		}
+		quote := pool.GetQuote(pool.XCoinInfo().Unit(), true)
+		if quote.OutputAmount == nil || (*big.Int)(quote.OutputAmount).Sign() <= 0 {
+			t.Errorf("GetQuote() of %s = %v", pool.PoolId(), quote.OutputAmount)
+		}
 	}
 }
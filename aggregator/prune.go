@@ -0,0 +1,138 @@
+package aggregator
+
+import (
+	"math/big"
+	"sort"
+
+	"github.com/omnibtc/go-hippo-sdk/aggregator/base"
+	"github.com/omnibtc/go-hippo-sdk/types"
+	"github.com/shopspring/decimal"
+)
+
+// CoinValuer value an amount of coin in a reference unit, such as USD
+type CoinValuer interface {
+	Value(coin types.CoinInfo, amount *big.Int) (decimal.Decimal, bool)
+}
+
+// LiquidityThreshold drop pools with too little liquidity from route search,
+// a pool is dropped when the reserve of either side is under its threshold
+type LiquidityThreshold struct {
+	// MinReserve is the min reserve in base units of coins not in CoinMinReserves
+	MinReserve *big.Int
+	// CoinMinReserves is the min reserve in base units keyed by coin full name
+	CoinMinReserves map[string]*big.Int
+	// MinValue is the min value of each reserve valued by Valuer, reserves which Valuer can not value are not checked
+	MinValue decimal.Decimal
+	Valuer   CoinValuer
+}
+
+// Filter return a PoolFilter which accepts pools above the threshold
+func (t LiquidityThreshold) Filter() PoolFilter {
+	return func(pool base.TradingPool) bool {
+		reserveX, reserveY := pool.Reserves()
+		return t.accept(pool.XCoinInfo(), reserveX) && t.accept(pool.YCoinInfo(), reserveY)
+	}
+}
+
+func (t LiquidityThreshold) accept(coin types.CoinInfo, reserve *big.Int) bool {
+	if reserve == nil {
+		return false
+	}
+	minReserve := t.MinReserve
+	if m, ok := t.CoinMinReserves[coin.TokenType.GetFullName()]; ok {
+		minReserve = m
+	}
+	if minReserve != nil && reserve.Cmp(minReserve) < 0 {
+		return false
+	}
+	if t.Valuer != nil && t.MinValue.IsPositive() {
+		if value, ok := t.Valuer.Value(coin, reserve); ok && value.LessThan(t.MinValue) {
+			return false
+		}
+	}
+	return true
+}
+
+// partialPath is a path from x to some coin, output is the amount of that coin from the probe amount of x
+type partialPath struct {
+	steps  []base.TradeStep
+	output *big.Int
+}
+
+// getTopKRoutes enumerate routes from x to y with at most maxSteps steps,
+// only the k partial paths with most output of the probe amount are extended from each intermediate coin.
+// Routes never pass x or y in the middle, so no round trip route is returned.
+func (a *TradeAggregator) getTopKRoutes(x, y types.CoinInfo, maxSteps int, intermediates []types.CoinInfo, filters []PoolFilter, probe *big.Int, k int) []base.TradeRoute {
+	xFullName := x.TokenType.GetFullName()
+	yFullName := y.TokenType.GetFullName()
+	middles := make([]types.CoinInfo, 0, len(intermediates))
+	for _, coin := range intermediates {
+		fullName := coin.TokenType.GetFullName()
+		if fullName == xFullName || fullName == yFullName {
+			continue
+		}
+		middles = append(middles, coin)
+	}
+
+	result := make([]base.TradeRoute, 0)
+	froms := []types.CoinInfo{x}
+	frontier := map[string][]partialPath{
+		xFullName: {{steps: nil, output: probe}},
+	}
+	for depth := 1; depth <= maxSteps; depth++ {
+		next := make(map[string][]partialPath)
+		nextFroms := make([]types.CoinInfo, 0)
+		for _, from := range froms {
+			paths := frontier[from.TokenType.GetFullName()]
+			// one step routes do not require routable pool, same as GetOneStepRoutes
+			for _, step := range a.getDirectSteps(from, y, depth > 1, filters) {
+				for _, path := range paths {
+					result = append(result, base.NewTradeRoute(appendStep(path.steps, step)))
+				}
+			}
+			if depth == maxSteps {
+				continue
+			}
+			for _, to := range middles {
+				toFullName := to.TokenType.GetFullName()
+				if toFullName == from.TokenType.GetFullName() {
+					continue
+				}
+				for _, step := range a.getDirectSteps(from, to, true, filters) {
+					for _, path := range paths {
+						if _, ok := next[toFullName]; !ok {
+							nextFroms = append(nextFroms, to)
+						}
+						next[toFullName] = append(next[toFullName], partialPath{
+							steps:  appendStep(path.steps, step),
+							output: step.GetQuote(path.output).OutputAmount,
+						})
+					}
+				}
+			}
+		}
+		for fullName, paths := range next {
+			next[fullName] = topKPaths(paths, k)
+		}
+		froms = nextFroms
+		frontier = next
+	}
+	return result
+}
+
+// topKPaths keep the k paths with most output
+func topKPaths(paths []partialPath, k int) []partialPath {
+	if len(paths) <= k {
+		return paths
+	}
+	sort.SliceStable(paths, func(i, j int) bool {
+		return paths[i].output.Cmp(paths[j].output) > 0
+	})
+	return paths[:k]
+}
+
+func appendStep(steps []base.TradeStep, step base.TradeStep) []base.TradeStep {
+	result := make([]base.TradeStep, len(steps), len(steps)+1)
+	copy(result, steps)
+	return append(result, step)
+}
@@ -0,0 +1,303 @@
+package aggregator
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/omnibtc/go-hippo-sdk/aggregator/base"
+	"github.com/omnibtc/go-hippo-sdk/types"
+)
+
+// AmountBucket map an input amount to its cache bucket, amounts in the same bucket share cached routes
+type AmountBucket func(amount *big.Int) string
+
+// ExactAmount put every amount in its own bucket
+func ExactAmount(amount *big.Int) string {
+	return amount.String()
+}
+
+// SignificantDigits bucket amounts by their first n significant digits,
+// eg. with n = 2, 123456 and 129999 are both in bucket 12e4
+func SignificantDigits(n int) AmountBucket {
+	return func(amount *big.Int) string {
+		s := amount.String()
+		if len(s) <= n {
+			return s
+		}
+		return fmt.Sprintf("%se%d", s[:n], len(s)-n)
+	}
+}
+
+type QuoteCacheStats struct {
+	Hits          uint64
+	Misses        uint64
+	Invalidations uint64
+	Entries       int
+}
+
+type quoteCacheEntry struct {
+	quotes []*base.RouteAndQuote
+	pools  map[base.PoolId]struct{}
+}
+
+// QuoteCache cache the quotes of GetQuotes, the entries using a pool are dropped when the pool reserves change
+type QuoteCache struct {
+	lock       sync.Mutex
+	bucket     AmountBucket
+	maxEntries int
+	entries    map[string]*quoteCacheEntry
+	byPool     map[base.PoolId]map[string]struct{}
+	stats      QuoteCacheStats
+	// generation is the aggregator generation of the entries, quotes of an older generation are not put
+	generation uint64
+}
+
+// NewQuoteCache create a cache holding at most maxEntries entries (unlimited if 0),
+// the cached routes of a bucket are quoted again for other amounts in it, nil bucket is ExactAmount
+func NewQuoteCache(maxEntries int, bucket AmountBucket) *QuoteCache {
+	if bucket == nil {
+		bucket = ExactAmount
+	}
+	return &QuoteCache{
+		bucket:     bucket,
+		maxEntries: maxEntries,
+		entries:    make(map[string]*quoteCacheEntry),
+		byPool:     make(map[base.PoolId]map[string]struct{}),
+	}
+}
+
+// get return copies of the cached quotes if they are quoted for inputAmount, or else nil quotes and the cached routes
+// to quote for inputAmount, since outputs of another amount of its bucket can not be scaled to it
+func (c *QuoteCache) get(key string, inputAmount *big.Int) ([]*base.RouteAndQuote, []base.TradeRoute, bool) {
+	c.lock.Lock()
+	entry, ok := c.entries[key]
+	if !ok {
+		c.stats.Misses++
+		c.lock.Unlock()
+		return nil, nil, false
+	}
+	c.stats.Hits++
+	quotes := entry.quotes
+	c.lock.Unlock()
+
+	if len(quotes) > 0 && ((*big.Int)(quotes[0].Quote.InputAmount)).Cmp(inputAmount) != 0 {
+		routes := make([]base.TradeRoute, len(quotes))
+		for i, q := range quotes {
+			routes[i] = q.Route
+		}
+		return nil, routes, true
+	}
+	result := make([]*base.RouteAndQuote, len(quotes))
+	for i, q := range quotes {
+		result[i] = copyQuote(q)
+	}
+	return result, nil, true
+}
+
+// copyQuote copy q so callers can not change the cached quote
+func copyQuote(q *base.RouteAndQuote) *base.RouteAndQuote {
+	quote := *q.Quote
+	quote.InputAmount = copyAmount(q.Quote.InputAmount)
+	quote.OutputAmount = copyAmount(q.Quote.OutputAmount)
+	return &base.RouteAndQuote{
+		Route:           q.Route,
+		Quote:           &quote,
+		GasCost:         copyAmount(q.GasCost),
+		NetOutputAmount: copyAmount(q.NetOutputAmount),
+	}
+}
+
+func copyAmount(amount *big.Int) *big.Int {
+	if amount == nil {
+		return nil
+	}
+	return new(big.Int).Set(amount)
+}
+
+// put cache quotes computed on generation, entry is dropped when any pool on the routes or in candidates changes
+func (c *QuoteCache) put(key string, generation uint64, quotes []*base.RouteAndQuote, candidates map[base.PoolId]struct{}) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if generation < c.generation {
+		// pools changed while quoting
+		return
+	}
+	c.generation = generation
+	if _, ok := c.entries[key]; !ok && c.maxEntries > 0 && len(c.entries) >= c.maxEntries {
+		// evict an arbitrary entry
+		for k := range c.entries {
+			c.removeLocked(k)
+			break
+		}
+	}
+	c.removeLocked(key)
+	entry := &quoteCacheEntry{
+		quotes: make([]*base.RouteAndQuote, len(quotes)),
+		pools:  make(map[base.PoolId]struct{}, len(candidates)),
+	}
+	for i, q := range quotes {
+		// callers own the quotes they are returned
+		entry.quotes[i] = copyQuote(q)
+		for _, step := range q.Route.Steps {
+			entry.pools[step.Pool.PoolId()] = struct{}{}
+		}
+	}
+	for id := range candidates {
+		entry.pools[id] = struct{}{}
+	}
+	for id := range entry.pools {
+		if _, ok := c.byPool[id]; !ok {
+			c.byPool[id] = make(map[string]struct{})
+		}
+		c.byPool[id][key] = struct{}{}
+	}
+	c.entries[key] = entry
+}
+
+// InvalidatePools drop every entry with a route using any of the pools
+func (c *QuoteCache) InvalidatePools(ids []base.PoolId) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.invalidatePoolsLocked(ids)
+}
+
+// invalidatePools drop the entries of pools and reject quotes older than generation
+func (c *QuoteCache) invalidatePools(generation uint64, ids []base.PoolId) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.generation = generation
+	c.invalidatePoolsLocked(ids)
+}
+
+func (c *QuoteCache) invalidatePoolsLocked(ids []base.PoolId) {
+	for _, id := range ids {
+		for key := range c.byPool[id] {
+			c.removeLocked(key)
+			c.stats.Invalidations++
+		}
+	}
+}
+
+// Clear drop all entries
+func (c *QuoteCache) Clear() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.clearLocked()
+}
+
+// reset drop all entries and reject quotes older than generation
+func (c *QuoteCache) reset(generation uint64) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.generation = generation
+	c.clearLocked()
+}
+
+func (c *QuoteCache) clearLocked() {
+	c.stats.Invalidations += uint64(len(c.entries))
+	c.entries = make(map[string]*quoteCacheEntry)
+	c.byPool = make(map[base.PoolId]map[string]struct{})
+}
+
+func (c *QuoteCache) Stats() QuoteCacheStats {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	stats := c.stats
+	stats.Entries = len(c.entries)
+	return stats
+}
+
+func (c *QuoteCache) removeLocked(key string) {
+	entry, ok := c.entries[key]
+	if !ok {
+		return
+	}
+	for id := range entry.pools {
+		delete(c.byPool[id], key)
+		if len(c.byPool[id]) == 0 {
+			delete(c.byPool, id)
+		}
+	}
+	delete(c.entries, key)
+}
+
+// key return the cache key of a GetQuotes call, false if the call can not be cached
+func (c *QuoteCache) key(inputAmount *big.Int, x, y types.CoinInfo, opts RouteOptions) (string, bool) {
+	routeKey, ok := routeCacheKey(x, y, opts)
+	if !ok {
+		return "", false
+	}
+	return routeKey + "|" + c.bucket(inputAmount), true
+}
+
+// cacheKey encode the options, false if options have pool filters which can not be compared
+func (o RouteOptions) cacheKey() (string, bool) {
+	if len(o.PoolFilters) > 0 {
+		return "", false
+	}
+	dexKey := func(dexes []base.DexType) string {
+		names := make([]string, len(dexes))
+		for i, d := range dexes {
+			names[i] = d.Name()
+		}
+		sort.Strings(names)
+		return strings.Join(names, ",")
+	}
+	intermediates := make([]string, len(o.AllowedIntermediates))
+	for i, t := range o.AllowedIntermediates {
+		intermediates[i] = t.GetFullName()
+	}
+	sort.Strings(intermediates)
+	return fmt.Sprintf("%d;%t;%s;%s;%s;%d;%t",
+		o.getMaxSteps(),
+		o.AllowRoundTrip,
+		dexKey(o.AllowedDexes),
+		dexKey(o.DeniedDexes),
+		strings.Join(intermediates, ","),
+		o.TopK,
+		o.AllowUnverified,
+	), true
+}
+
+// changedPools return ids of old pools whose reserves differ in current or which are removed
+func changedPools(old, current *PoolRegistry) []base.PoolId {
+	changed := make([]base.PoolId, 0)
+	for _, p := range old.Pools() {
+		id := p.PoolId()
+		n, ok := current.GetPool(id)
+		if !ok {
+			changed = append(changed, id)
+			continue
+		}
+		oldX, oldY := p.Reserves()
+		newX, newY := n.Reserves()
+		if !equalAmount(oldX, newX) || !equalAmount(oldY, newY) {
+			changed = append(changed, id)
+		}
+	}
+	return changed
+}
+
+// poolRecorder record the pools a route search looks at, its filter accepts every pool
+type poolRecorder struct {
+	ids map[base.PoolId]struct{}
+}
+
+func newPoolRecorder() *poolRecorder {
+	return &poolRecorder{ids: make(map[base.PoolId]struct{})}
+}
+
+func (r *poolRecorder) filter(pool base.TradingPool) bool {
+	r.ids[pool.PoolId()] = struct{}{}
+	return true
+}
+
+func equalAmount(a, b *big.Int) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Cmp(b) == 0
+}
@@ -0,0 +1,128 @@
+package aggregator
+
+import (
+	"github.com/omnibtc/go-hippo-sdk/aggregator/base"
+	"github.com/omnibtc/go-hippo-sdk/types"
+)
+
+// PoolRegistry index the loaded pools by id, coin pair, DEX and coin
+type PoolRegistry struct {
+	pools  []base.TradingPool
+	byId   map[base.PoolId]base.TradingPool
+	byPair map[string][]base.TradingPool
+	byDex  map[base.DexType][]base.TradingPool
+	byCoin map[string][]base.TradingPool
+}
+
+// NewPoolRegistry index pools, a pool with the same id as an earlier one is dropped
+func NewPoolRegistry(pools []base.TradingPool) *PoolRegistry {
+	r := &PoolRegistry{
+		pools:  make([]base.TradingPool, 0, len(pools)),
+		byId:   make(map[base.PoolId]base.TradingPool),
+		byPair: make(map[string][]base.TradingPool),
+		byDex:  make(map[base.DexType][]base.TradingPool),
+		byCoin: make(map[string][]base.TradingPool),
+	}
+	for _, p := range pools {
+		id := p.PoolId()
+		if _, ok := r.byId[id]; ok {
+			continue
+		}
+		xFullName := p.XCoinInfo().TokenType.GetFullName()
+		yFullName := p.YCoinInfo().TokenType.GetFullName()
+		pairKey := getPairKey(xFullName, yFullName)
+
+		r.pools = append(r.pools, p)
+		r.byId[id] = p
+		r.byPair[pairKey] = append(r.byPair[pairKey], p)
+		r.byDex[p.DexType()] = append(r.byDex[p.DexType()], p)
+		r.byCoin[xFullName] = append(r.byCoin[xFullName], p)
+		if yFullName != xFullName {
+			r.byCoin[yFullName] = append(r.byCoin[yFullName], p)
+		}
+	}
+	return r
+}
+
+// Pools return all pools in load order
+func (r *PoolRegistry) Pools() []base.TradingPool {
+	return r.pools
+}
+
+func (r *PoolRegistry) GetPool(id base.PoolId) (base.TradingPool, bool) {
+	p, ok := r.byId[id]
+	return p, ok
+}
+
+// GetPoolsByPair return pools trading x and y, in either X/Y order of the pool
+func (r *PoolRegistry) GetPoolsByPair(x, y types.TokenType) []base.TradingPool {
+	return r.byPair[getPairKey(x.GetFullName(), y.GetFullName())]
+}
+
+func (r *PoolRegistry) GetPoolsByDex(dex base.DexType) []base.TradingPool {
+	return r.byDex[dex]
+}
+
+// GetPoolsByCoin return pools which have coin as X or Y
+func (r *PoolRegistry) GetPoolsByCoin(coin types.TokenType) []base.TradingPool {
+	return r.byCoin[coin.GetFullName()]
+}
+
+func getPairKey(xFullName, yFullName string) string {
+	if xFullName > yFullName {
+		xFullName, yFullName = yFullName, xFullName
+	}
+	return xFullName + "|" + yFullName
+}
+
+// PoolFilter report whether a pool can be used in a route
+type PoolFilter func(pool base.TradingPool) bool
+
+// IncludeDexes only allow pools of dexes
+func IncludeDexes(dexes ...base.DexType) PoolFilter {
+	s := make(map[base.DexType]struct{}, len(dexes))
+	for _, d := range dexes {
+		s[d] = struct{}{}
+	}
+	return func(pool base.TradingPool) bool {
+		_, ok := s[pool.DexType()]
+		return ok
+	}
+}
+
+// ExcludeDexes forbid pools of dexes
+func ExcludeDexes(dexes ...base.DexType) PoolFilter {
+	include := IncludeDexes(dexes...)
+	return func(pool base.TradingPool) bool {
+		return !include(pool)
+	}
+}
+
+// IncludePools only allow the pools of ids
+func IncludePools(ids ...base.PoolId) PoolFilter {
+	s := make(map[base.PoolId]struct{}, len(ids))
+	for _, id := range ids {
+		s[id] = struct{}{}
+	}
+	return func(pool base.TradingPool) bool {
+		_, ok := s[pool.PoolId()]
+		return ok
+	}
+}
+
+// ExcludePools forbid the pools of ids
+func ExcludePools(ids ...base.PoolId) PoolFilter {
+	include := IncludePools(ids...)
+	return func(pool base.TradingPool) bool {
+		return !include(pool)
+	}
+}
+
+func matchPoolFilters(pool base.TradingPool, filters []PoolFilter) bool {
+	for _, f := range filters {
+		if !f(pool) {
+			return false
+		}
+	}
+	return true
+}
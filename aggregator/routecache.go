@@ -0,0 +1,147 @@
+package aggregator
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/omnibtc/go-hippo-sdk/aggregator/base"
+	"github.com/omnibtc/go-hippo-sdk/types"
+)
+
+// poolUsage is how route search can use a pool
+type poolUsage struct {
+	routable bool
+	liquid   bool
+}
+
+// routeCache memoize the routes of each pair and options.
+// Routes only depend on which pools can be used, so while that stays the same after a reload
+// the cached routes are rebound to the reloaded pools instead of searched again.
+type routeCache struct {
+	lock     sync.RWMutex
+	topology map[base.PoolId]poolUsage
+	routes   map[string][]base.TradeRoute
+	// generation is incremented by every reload, routes searched on an older generation may hold stale pools
+	generation uint64
+}
+
+func newRouteCache() *routeCache {
+	return &routeCache{
+		routes: make(map[string][]base.TradeRoute),
+	}
+}
+
+// get return the cached routes, or the generation to put the routes searched on a miss
+func (c *routeCache) get(key string) ([]base.TradeRoute, uint64, bool) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	routes, ok := c.routes[key]
+	if !ok {
+		return nil, c.generation, false
+	}
+	return append([]base.TradeRoute{}, routes...), c.generation, true
+}
+
+// put cache routes searched on generation, they are dropped if pools were reloaded since
+func (c *routeCache) put(key string, generation uint64, routes []base.TradeRoute) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if generation != c.generation {
+		return
+	}
+	c.routes[key] = append([]base.TradeRoute{}, routes...)
+}
+
+// reload rebind cached routes to the pools of registry if topology is unchanged, else drop them
+func (c *routeCache) reload(topology map[base.PoolId]poolUsage, registry *PoolRegistry) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.generation++
+	if !sameTopology(c.topology, topology) {
+		c.topology = topology
+		c.routes = make(map[string][]base.TradeRoute)
+		return
+	}
+	rebound := make(map[string][]base.TradeRoute, len(c.routes))
+	for key, routes := range c.routes {
+		result := make([]base.TradeRoute, 0, len(routes))
+		ok := true
+		for _, route := range routes {
+			var r base.TradeRoute
+			if r, ok = rebindRoute(route, registry); !ok {
+				break
+			}
+			result = append(result, r)
+		}
+		if ok {
+			rebound[key] = result
+		}
+	}
+	c.routes = rebound
+}
+
+// clear drop all routes, eg. when the intermediate coins change
+func (c *routeCache) clear() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.generation++
+	c.routes = make(map[string][]base.TradeRoute)
+}
+
+// reset drop all routes and set the topology
+func (c *routeCache) reset(topology map[base.PoolId]poolUsage) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.generation++
+	c.topology = topology
+	c.routes = make(map[string][]base.TradeRoute)
+}
+
+// routeCacheKey return the cache key of the routes from x to y, false if opts can not be cached
+func routeCacheKey(x, y types.CoinInfo, opts RouteOptions) (string, bool) {
+	optsKey, ok := opts.cacheKey()
+	if !ok {
+		return "", false
+	}
+	return strings.Join([]string{
+		x.TokenType.GetFullName(),
+		y.TokenType.GetFullName(),
+		optsKey,
+	}, "|"), true
+}
+
+func rebindRoute(route base.TradeRoute, registry *PoolRegistry) (base.TradeRoute, bool) {
+	steps := make([]base.TradeStep, len(route.Steps))
+	for i, step := range route.Steps {
+		pool, ok := registry.GetPool(step.Pool.PoolId())
+		if !ok {
+			return base.TradeRoute{}, false
+		}
+		steps[i] = base.NewTradeStep(pool, step.IsXtoY)
+	}
+	return base.TradeRoute{Tokens: route.Tokens, Steps: steps}, true
+}
+
+func sameTopology(a, b map[base.PoolId]poolUsage) bool {
+	if a == nil || b == nil || len(a) != len(b) {
+		return false
+	}
+	for id, usage := range a {
+		if other, ok := b[id]; !ok || other != usage {
+			return false
+		}
+	}
+	return true
+}
+
+// routeTopology return how route search can use each pool of registry, liquidityFilter is nil if no threshold
+func routeTopology(registry *PoolRegistry, liquidityFilter PoolFilter) map[base.PoolId]poolUsage {
+	topology := make(map[base.PoolId]poolUsage, len(registry.Pools()))
+	for _, p := range registry.Pools() {
+		topology[p.PoolId()] = poolUsage{
+			routable: p.IsRoutable(),
+			liquid:   liquidityFilter == nil || liquidityFilter(p),
+		}
+	}
+	return topology
+}
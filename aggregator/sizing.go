@@ -0,0 +1,244 @@
+package aggregator
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/omnibtc/go-hippo-sdk/aggregator/base"
+)
+
+type sizingKind int
+
+const (
+	sizingMaxProfit sizingKind = iota
+	sizingMaxImpact
+)
+
+// SizingTarget is what OptimizeInput maximizes
+type SizingTarget struct {
+	kind         sizingKind
+	maxImpactBps uint64
+}
+
+// MaxProfit maximize output minus input, the route must start and end at the same coin
+func MaxProfit() SizingTarget {
+	return SizingTarget{kind: sizingMaxProfit}
+}
+
+// MaxInputWithinImpact find the largest input whose price impact is at most bps,
+// price impact is how much the average price is worse than the marginal price of a tiny input
+func MaxInputWithinImpact(bps uint64) SizingTarget {
+	return SizingTarget{kind: sizingMaxImpact, maxImpactBps: bps}
+}
+
+type InputSizing struct {
+	InputAmount  *big.Int
+	OutputAmount *big.Int
+	// ClosedForm is true if every pool of the route is constant product and the input is solved directly
+	ClosedForm bool
+}
+
+// OptimizeInput find the input amount in [0, maxInput] of route best for target,
+// nil maxInput is the input reserve of the first pool.
+// Routes of constant product pools are solved in closed form, other routes are searched with GetQuote.
+func OptimizeInput(route base.TradeRoute, target SizingTarget, maxInput *big.Int) (*InputSizing, error) {
+	if target.kind == sizingMaxProfit && route.XCoinInfo().TokenType.GetFullName() != route.YCoinInfo().TokenType.GetFullName() {
+		return nil, errors.New("profit target needs a route from and to the same coin")
+	}
+	if maxInput == nil {
+		maxInput = routeInputReserve(route)
+	}
+	if maxInput == nil || maxInput.Sign() <= 0 {
+		return nil, errors.New("route has no input reserve")
+	}
+
+	var input *big.Int
+	closedForm := false
+	if target.kind == sizingMaxImpact && target.maxImpactBps >= 10000 {
+		// every input is within impact
+		input = maxInput
+	} else if curve, ok := newConstantProductCurve(route); ok {
+		closedForm = true
+		switch target.kind {
+		case sizingMaxProfit:
+			input = curve.maxProfitInput()
+		default:
+			input = curve.maxImpactInput(target.maxImpactBps)
+		}
+		if input.Cmp(maxInput) > 0 {
+			input = maxInput
+		}
+	} else {
+		var err error
+		switch target.kind {
+		case sizingMaxProfit:
+			input, _ = maximizeProfit(route, maxInput)
+		default:
+			input, err = searchMaxImpactInput(route, target.maxImpactBps, maxInput)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &InputSizing{
+		InputAmount:  input,
+		OutputAmount: route.GetQuote(input).OutputAmount,
+		ClosedForm:   closedForm,
+	}, nil
+}
+
+// routeInputReserve return the reserve of the input coin in the first pool
+func routeInputReserve(route base.TradeRoute) *big.Int {
+	step := route.Steps[0]
+	reserveX, reserveY := step.Pool.Reserves()
+	if step.IsXtoY {
+		return reserveX
+	}
+	return reserveY
+}
+
+// constantProductCurve is the output of a route of constant product pools, out = a*in / (b + c*in)
+type constantProductCurve struct {
+	a, b, c *big.Rat
+}
+
+// newConstantProductCurve compose the curves of each step, false if any pool is not constant product
+func newConstantProductCurve(route base.TradeRoute) (*constantProductCurve, bool) {
+	var curve *constantProductCurve
+	for _, step := range route.Steps {
+		pool, ok := step.Pool.(base.ConstantProductPool)
+		if !ok {
+			return nil, false
+		}
+		params, ok := pool.ConstantProductParams(step.IsXtoY)
+		if !ok || params.ReserveIn == nil || params.ReserveOut == nil || params.ReserveIn.Sign() <= 0 {
+			return nil, false
+		}
+		// out = OutputFactor*ReserveOut*InputFactor*in / (ReserveIn + InputFactor*in)
+		stepCurve := &constantProductCurve{
+			a: new(big.Rat).Mul(new(big.Rat).Mul(params.OutputFactor, new(big.Rat).SetInt(params.ReserveOut)), params.InputFactor),
+			b: new(big.Rat).SetInt(params.ReserveIn),
+			c: new(big.Rat).Set(params.InputFactor),
+		}
+		if curve == nil {
+			curve = stepCurve
+			continue
+		}
+		// feed curve into stepCurve: a = a1*a2, b = b1*b2, c = b2*c1 + c2*a1
+		curve = &constantProductCurve{
+			a: new(big.Rat).Mul(curve.a, stepCurve.a),
+			b: new(big.Rat).Mul(curve.b, stepCurve.b),
+			c: new(big.Rat).Add(
+				new(big.Rat).Mul(stepCurve.b, curve.c),
+				new(big.Rat).Mul(stepCurve.c, curve.a),
+			),
+		}
+	}
+	return curve, curve != nil
+}
+
+// maxProfitInput solve d(out - in)/d(in) = 0, in = (sqrt(a*b) - b) / c, 0 if no input is profitable
+func (k *constantProductCurve) maxProfitInput() *big.Int {
+	if k.a.Cmp(k.b) <= 0 {
+		return big.NewInt(0)
+	}
+	ab := new(big.Float).SetPrec(256).SetRat(new(big.Rat).Mul(k.a, k.b))
+	sqrt, _ := new(big.Float).SetPrec(256).Sqrt(ab).Rat(nil)
+	input := new(big.Rat).Quo(new(big.Rat).Sub(sqrt, k.b), k.c)
+	return ratFloor(input)
+}
+
+// maxImpactInput solve c*in / (b + c*in) = bps/10000, in = bps*b / (c*(10000-bps))
+func (k *constantProductCurve) maxImpactInput(bps uint64) *big.Int {
+	numerator := new(big.Rat).Mul(new(big.Rat).SetInt64(int64(bps)), k.b)
+	denominator := new(big.Rat).Mul(k.c, new(big.Rat).SetInt64(int64(10000-bps)))
+	return ratFloor(new(big.Rat).Quo(numerator, denominator))
+}
+
+func ratFloor(r *big.Rat) *big.Int {
+	if r.Sign() <= 0 {
+		return big.NewInt(0)
+	}
+	return new(big.Int).Quo(r.Num(), r.Denom())
+}
+
+// maximizeProfit ternary search the input in [1, maxInput] with most output minus input,
+// which assumes the profit is unimodal as it is for the concave output of AMM pools
+func maximizeProfit(route base.TradeRoute, maxInput *big.Int) (input, profit *big.Int) {
+	profitOf := func(in *big.Int) *big.Int {
+		out := route.GetQuote(in).OutputAmount
+		return big.NewInt(0).Sub(out, in)
+	}
+	lo := big.NewInt(1)
+	hi := big.NewInt(0).Set(maxInput)
+	three := big.NewInt(3)
+	for big.NewInt(0).Sub(hi, lo).Cmp(three) >= 0 {
+		third := big.NewInt(0).Div(big.NewInt(0).Sub(hi, lo), three)
+		m1 := big.NewInt(0).Add(lo, third)
+		m2 := big.NewInt(0).Sub(hi, third)
+		if profitOf(m1).Cmp(profitOf(m2)) < 0 {
+			lo = m1
+		} else {
+			hi = m2
+		}
+	}
+	input, profit = lo, profitOf(lo)
+	for in := big.NewInt(0).Add(lo, big.NewInt(1)); in.Cmp(hi) <= 0; in = big.NewInt(0).Add(in, big.NewInt(1)) {
+		if p := profitOf(in); p.Cmp(profit) > 0 {
+			input, profit = in, p
+		}
+	}
+	return input, profit
+}
+
+// sizingProbeDivisor set the probe input of the marginal price to maxInput / sizingProbeDivisor
+const sizingProbeDivisor = 1000000
+
+// searchMaxImpactInput binary search the largest input in [0, maxInput] within bps price impact,
+// which assumes the price impact grows with input
+func searchMaxImpactInput(route base.TradeRoute, bps uint64, maxInput *big.Int) (*big.Int, error) {
+	spot, err := spotRate(route, maxInput)
+	if err != nil {
+		return nil, err
+	}
+	minRate := new(big.Rat).Mul(spot, big.NewRat(int64(10000-bps), 10000))
+	return searchMaxInputAtRate(route, minRate, maxInput), nil
+}
+
+// spotRate return the output per input of a tiny probe input, which is the marginal price of route
+func spotRate(route base.TradeRoute, maxInput *big.Int) (*big.Rat, error) {
+	probe := big.NewInt(0).Div(maxInput, big.NewInt(sizingProbeDivisor))
+	if probe.Sign() == 0 {
+		probe = big.NewInt(1)
+	}
+	probeOut := (*big.Int)(route.GetQuote(probe).OutputAmount)
+	if probeOut.Sign() <= 0 {
+		return nil, errors.New("route has no output")
+	}
+	return new(big.Rat).SetFrac(probeOut, probe), nil
+}
+
+// searchMaxInputAtRate binary search the largest input in [0, maxInput] with output per input at least minRate,
+// which assumes the average price gets worse with input
+func searchMaxInputAtRate(route base.TradeRoute, minRate *big.Rat, maxInput *big.Int) *big.Int {
+	// out * rate.Denom >= in * rate.Num
+	within := func(in *big.Int) bool {
+		out := (*big.Int)(route.GetQuote(in).OutputAmount)
+		lhs := big.NewInt(0).Mul(out, minRate.Denom())
+		return lhs.Cmp(big.NewInt(0).Mul(in, minRate.Num())) >= 0
+	}
+	lo := big.NewInt(0)
+	hi := big.NewInt(0).Set(maxInput)
+	for lo.Cmp(hi) < 0 {
+		// mid rounds up so lo always moves
+		mid := big.NewInt(0).Add(lo, hi)
+		mid.Add(mid, big.NewInt(1))
+		mid.Rsh(mid, 1)
+		if within(mid) {
+			lo = mid
+		} else {
+			hi = mid.Sub(mid, big.NewInt(1))
+		}
+	}
+	return lo
+}
@@ -0,0 +1,164 @@
+package twap
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/omnibtc/go-hippo-sdk/aggregator/base"
+)
+
+var (
+	ErrNoHistory        = errors.New("no snapshot of pool")
+	ErrNotEnoughHistory = errors.New("snapshots do not cover the window")
+
+	// q64 is 1 in UQ64x64 fixed point
+	q64 = new(big.Int).Lsh(big.NewInt(1), 64)
+	// q128 is where accumulators wrap around, same as u128 on chain
+	q128 = new(big.Int).Lsh(big.NewInt(1), 128)
+)
+
+// Snapshot is the price accumulators of a pool at a time
+type Snapshot struct {
+	// Timestamp is the seconds the snapshot is taken at
+	Timestamp uint64
+	// PriceXCumulative sum the UQ64x64 price of X in Y over seconds, modulo 2^128
+	PriceXCumulative *big.Int
+	// PriceYCumulative sum the UQ64x64 price of Y in X over seconds, modulo 2^128
+	PriceYCumulative *big.Int
+	reserveX         *big.Int
+	reserveY         *big.Int
+}
+
+// Tracker store snapshots of pool price accumulators and compute time weighted average prices.
+// Pools with on-chain accumulators (base.CumulativePricePool, eg. AnimeSwap) are read directly,
+// the accumulators of other pools are summed from the reserves of successive snapshots,
+// at the time reserves changed if the pool records it (base.ReservesTimestampPool, eg. Pancake).
+type Tracker struct {
+	lock       sync.Mutex
+	maxHistory time.Duration
+	history    map[base.PoolId][]Snapshot
+}
+
+// NewTracker keep snapshots of the last maxHistory, which must cover the largest window used
+func NewTracker(maxHistory time.Duration) *Tracker {
+	return &Tracker{
+		maxHistory: maxHistory,
+		history:    make(map[base.PoolId][]Snapshot),
+	}
+}
+
+// Observe take a snapshot of pool at now, which must be later than its previous snapshot
+func (t *Tracker) Observe(pool base.TradingPool, now time.Time) error {
+	reserveX, reserveY := pool.Reserves()
+	if reserveX == nil || reserveY == nil || reserveX.Sign() <= 0 || reserveY.Sign() <= 0 {
+		return fmt.Errorf("pool %s has no reserves", pool.PoolId())
+	}
+	timestamp := uint64(now.Unix())
+	id := pool.PoolId()
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	history := t.history[id]
+	var last *Snapshot
+	if len(history) > 0 {
+		last = &history[len(history)-1]
+		if timestamp <= last.Timestamp {
+			return fmt.Errorf("snapshot at %d is not after the last snapshot at %d", timestamp, last.Timestamp)
+		}
+	}
+
+	snapshot := Snapshot{Timestamp: timestamp, reserveX: reserveX, reserveY: reserveY}
+	if p, ok := pool.(base.CumulativePricePool); ok {
+		// extend the on-chain accumulators to now with the current price, they are only updated on swaps
+		prices := p.CumulativePrices()
+		elapsed := uint64(0)
+		if timestamp > prices.Timestamp {
+			elapsed = timestamp - prices.Timestamp
+		}
+		snapshot.PriceXCumulative = accumulate(prices.PriceXCumulative, reserveY, reserveX, elapsed)
+		snapshot.PriceYCumulative = accumulate(prices.PriceYCumulative, reserveX, reserveY, elapsed)
+	} else if last == nil {
+		snapshot.PriceXCumulative = big.NewInt(0)
+		snapshot.PriceYCumulative = big.NewInt(0)
+	} else {
+		// the reserves of last snapshot hold until they change, then the current reserves hold until now
+		changedAt := timestamp
+		if p, ok := pool.(base.ReservesTimestampPool); ok {
+			if ts := p.ReservesTimestamp(); ts > last.Timestamp && ts < timestamp {
+				changedAt = ts
+			}
+		}
+		snapshot.PriceXCumulative = accumulate(last.PriceXCumulative, last.reserveY, last.reserveX, changedAt-last.Timestamp)
+		snapshot.PriceXCumulative = accumulate(snapshot.PriceXCumulative, reserveY, reserveX, timestamp-changedAt)
+		snapshot.PriceYCumulative = accumulate(last.PriceYCumulative, last.reserveX, last.reserveY, changedAt-last.Timestamp)
+		snapshot.PriceYCumulative = accumulate(snapshot.PriceYCumulative, reserveX, reserveY, timestamp-changedAt)
+	}
+
+	history = append(history, snapshot)
+	// keep one snapshot older than max history, so windows of max history are covered
+	drop := 0
+	for drop+1 < len(history) && timestamp-history[drop+1].Timestamp >= uint64(t.maxHistory.Seconds()) {
+		drop++
+	}
+	t.history[id] = history[drop:]
+	return nil
+}
+
+// ObservePools take a snapshot of every pool at now, pools which can not be observed are skipped
+func (t *Tracker) ObservePools(pools []base.TradingPool, now time.Time) {
+	for _, pool := range pools {
+		_ = t.Observe(pool, now)
+	}
+}
+
+// Snapshots return the snapshots of pool, oldest first
+func (t *Tracker) Snapshots(id base.PoolId) []Snapshot {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return append([]Snapshot{}, t.history[id]...)
+}
+
+// TWAP return the time weighted average prices of X in Y and Y in X in base units over the window
+// ending at the latest snapshot, the window starts at the latest snapshot at least window before it
+func (t *Tracker) TWAP(id base.PoolId, window time.Duration) (priceX, priceY *big.Rat, err error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	history := t.history[id]
+	if len(history) == 0 {
+		return nil, nil, ErrNoHistory
+	}
+	end := history[len(history)-1]
+	seconds := uint64(window.Seconds())
+	var start *Snapshot
+	for i := len(history) - 2; i >= 0; i-- {
+		if end.Timestamp-history[i].Timestamp >= seconds {
+			start = &history[i]
+			break
+		}
+	}
+	if start == nil {
+		return nil, nil, ErrNotEnoughHistory
+	}
+	elapsed := end.Timestamp - start.Timestamp
+	return average(start.PriceXCumulative, end.PriceXCumulative, elapsed),
+		average(start.PriceYCumulative, end.PriceYCumulative, elapsed), nil
+}
+
+// accumulate add the UQ64x64 price numerator/denominator times seconds to cumulative, modulo 2^128
+func accumulate(cumulative, numerator, denominator *big.Int, seconds uint64) *big.Int {
+	price := new(big.Int).Lsh(numerator, 64)
+	price.Quo(price, denominator)
+	result := new(big.Int).Mul(price, new(big.Int).SetUint64(seconds))
+	result.Add(result, cumulative)
+	return result.Mod(result, q128)
+}
+
+// average return (end - start) / seconds as a price, the difference is taken modulo 2^128 for wrapped accumulators
+func average(start, end *big.Int, seconds uint64) *big.Rat {
+	diff := new(big.Int).Sub(end, start)
+	diff.Mod(diff, q128)
+	return new(big.Rat).SetFrac(diff, new(big.Int).Mul(q64, new(big.Int).SetUint64(seconds)))
+}
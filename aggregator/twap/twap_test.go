@@ -0,0 +1,104 @@
+package twap
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/omnibtc/go-hippo-sdk/aggregator/base"
+)
+
+type fakePool struct {
+	base.TradingPool
+	reserveX, reserveY *big.Int
+	changedAt          uint64
+}
+
+func (p *fakePool) PoolId() base.PoolId                     { return base.PoolId{Dex: base.Pancake, OwnerAddress: "0x1"} }
+func (p *fakePool) Reserves() (reserveX, reserveY *big.Int) { return p.reserveX, p.reserveY }
+func (p *fakePool) ReservesTimestamp() uint64               { return p.changedAt }
+
+type fakeCumulativePool struct {
+	fakePool
+	prices base.CumulativePrices
+}
+
+func (p *fakeCumulativePool) CumulativePrices() base.CumulativePrices { return p.prices }
+
+func TestTracker_ReservesTimestamp(t *testing.T) {
+	tracker := NewTracker(time.Hour)
+	pool := &fakePool{reserveX: big.NewInt(100), reserveY: big.NewInt(200)}
+	start := time.Unix(1000, 0)
+	if err := tracker.Observe(pool, start); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := tracker.TWAP(pool.PoolId(), time.Minute); err != ErrNotEnoughHistory {
+		t.Errorf("TWAP() err = %v, want ErrNotEnoughHistory", err)
+	}
+
+	// price 2 for 30s, then 4 for 70s
+	pool.reserveY = big.NewInt(400)
+	pool.changedAt = 1030
+	if err := tracker.Observe(pool, start.Add(100*time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	priceX, priceY, err := tracker.TWAP(pool.PoolId(), 100*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f, _ := priceX.Float64(); f < 3.39 || f > 3.41 {
+		t.Errorf("priceX = %f, want 3.4", f)
+	}
+	if f, _ := priceY.Float64(); f < 0.3249 || f > 0.3251 {
+		t.Errorf("priceY = %f, want 0.325", f)
+	}
+	if err := tracker.Observe(pool, start); err == nil {
+		t.Errorf("Observe() before last snapshot, want error")
+	}
+}
+
+func TestTracker_CumulativePrices(t *testing.T) {
+	tracker := NewTracker(time.Hour)
+	// accumulator near 2^128, it wraps before the next snapshot
+	near := new(big.Int).Sub(q128, new(big.Int).Lsh(big.NewInt(10), 64))
+	pool := &fakeCumulativePool{
+		fakePool: fakePool{reserveX: big.NewInt(100), reserveY: big.NewInt(300)},
+		prices:   base.CumulativePrices{PriceXCumulative: near, PriceYCumulative: big.NewInt(0), Timestamp: 1000},
+	}
+	if err := tracker.Observe(pool, time.Unix(1000, 0)); err != nil {
+		t.Fatal(err)
+	}
+	// on chain price was 5 for 50s, current price 3 held since
+	pool.prices = base.CumulativePrices{
+		PriceXCumulative: accumulate(near, big.NewInt(5), big.NewInt(1), 50),
+		PriceYCumulative: accumulate(big.NewInt(0), big.NewInt(1), big.NewInt(5), 50),
+		Timestamp:        1050,
+	}
+	if err := tracker.Observe(pool, time.Unix(1100, 0)); err != nil {
+		t.Fatal(err)
+	}
+	priceX, _, err := tracker.TWAP(pool.PoolId(), 100*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f, _ := priceX.Float64(); f < 3.99 || f > 4.01 {
+		t.Errorf("priceX = %f, want 4", f)
+	}
+}
+
+func TestTracker_MaxHistory(t *testing.T) {
+	tracker := NewTracker(time.Minute)
+	pool := &fakePool{reserveX: big.NewInt(100), reserveY: big.NewInt(200)}
+	for i := int64(0); i < 10; i++ {
+		if err := tracker.Observe(pool, time.Unix(1000+i*20, 0)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// snapshots at 1120, 1140, 1160, 1180, the oldest is kept to cover a full minute
+	if got := len(tracker.Snapshots(pool.PoolId())); got != 4 {
+		t.Errorf("Snapshots() = %d, want 4", got)
+	}
+	if _, _, err := tracker.TWAP(pool.PoolId(), time.Minute); err != nil {
+		t.Errorf("TWAP() err = %v", err)
+	}
+}
@@ -1,13 +1,18 @@
 package contract
 
 import (
+	_ "embed"
 	"encoding/json"
-	"github.com/omnibtc/go-hippo-sdk/types"
-	"io/ioutil"
+	"fmt"
+	"io"
 	"os"
-	"strings"
+
+	"github.com/omnibtc/go-hippo-sdk/types"
 )
 
+//go:embed datajson.json
+var defaultCoinList []byte
+
 type CoinListApp interface {
 	QueryFetchFullList() (list []types.CoinInfo, err error)
 }
@@ -34,50 +39,151 @@ func (c *CustomCoinListApp) Append(coinList []types.CoinInfo) {
 	c.coinList = append(c.coinList, coinList...)
 }
 
+// DevCoinListApp is the coin list embedded in the sdk
 type DevCoinListApp struct {
 }
 
+// NewDevCoinListApp is the same as NewEmbeddedCoinListApp
 func NewDevCoinListApp() CoinListApp {
 	return &DevCoinListApp{}
 }
 
+// NewEmbeddedCoinListApp return the hippo coin list embedded in the sdk, it does not need any file or network
+func NewEmbeddedCoinListApp() CoinListApp {
+	return &DevCoinListApp{}
+}
+
 func (c *DevCoinListApp) QueryFetchFullList() (list []types.CoinInfo, err error) {
-	coinList := make([]types.CoinInfo, 0)
-	coinFile, err := os.Open("contract/datajson.json")
+	return ParseCoinList(defaultCoinList)
+}
+
+// FileCoinListApp read a hippo coin list json file on every fetch
+type FileCoinListApp struct {
+	path string
+}
+
+func NewFileCoinListApp(path string) CoinListApp {
+	return &FileCoinListApp{path: path}
+}
+
+func (c *FileCoinListApp) QueryFetchFullList() ([]types.CoinInfo, error) {
+	data, err := os.ReadFile(c.path)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
-	defer coinFile.Close()
-	var arr []coinInfo
-	coinValue, _ := ioutil.ReadAll(coinFile)
-	err = json.Unmarshal(coinValue, &arr)
+	return ParseCoinList(data)
+}
+
+// NewReaderCoinListApp read a hippo coin list json from r once
+func NewReaderCoinListApp(r io.Reader) (CoinListApp, error) {
+	data, err := io.ReadAll(r)
 	if err != nil {
-		return
+		return nil, err
 	}
+	coinList, err := ParseCoinList(data)
+	if err != nil {
+		return nil, err
+	}
+	return NewCustomCoinListApp(coinList), nil
+}
 
-	for _, v := range arr {
-		typesArr := strings.Split(v.TokenType.Type, "::")
-		coinList = append(coinList, types.CoinInfo{
-			Name:     v.Name,
-			Decimals: int(v.Decimals),
-			Symbol:   v.Symbol,
-			TokenType: &types.StructTag{
-				Address: typesArr[0],
-				Module:  typesArr[1],
-				Name:    typesArr[2],
-			},
-		})
+// MergedCoinListApp concatenate the lists of several apps,
+// a coin in more than one list is taken from the first app which has it
+type MergedCoinListApp struct {
+	apps []CoinListApp
+}
+
+func NewMergedCoinListApp(apps ...CoinListApp) CoinListApp {
+	return &MergedCoinListApp{apps: apps}
+}
+
+func (c *MergedCoinListApp) QueryFetchFullList() ([]types.CoinInfo, error) {
+	coinList := make([]types.CoinInfo, 0)
+	seen := make(map[string]bool)
+	for _, app := range c.apps {
+		list, err := app.QueryFetchFullList()
+		if err != nil {
+			return nil, err
+		}
+		for _, coin := range list {
+			fullName := types.CanonicalTypeName(coin.TokenType.GetFullName())
+			if seen[fullName] {
+				continue
+			}
+			seen[fullName] = true
+			coinList = append(coinList, coin)
+		}
 	}
+	return coinList, nil
+}
 
+// ParseCoinList decode a coin list in the format of hippo aptos-coin-list,
+// tags and bridge are only taken from the tags and extensions of the list, a coin with a bridge is also tagged wrapped
+func ParseCoinList(data []byte) ([]types.CoinInfo, error) {
+	var arr []coinInfo
+	if err := json.Unmarshal(data, &arr); err != nil {
+		return nil, err
+	}
+	coinList := make([]types.CoinInfo, 0, len(arr))
+	for _, v := range arr {
+		tag, err := types.ParseMoveStructTag(v.TokenType.Type)
+		if err != nil {
+			return nil, fmt.Errorf("coin %s: %v", v.Symbol, err)
+		}
+		extensions := make(map[string]string, len(v.Extensions.Data))
+		for _, kv := range v.Extensions.Data {
+			if len(kv) == 2 {
+				extensions[kv[0]] = kv[1]
+			}
+		}
+		coin := types.CoinInfo{
+			Name:           v.Name,
+			Decimals:       int(v.Decimals),
+			Symbol:         v.Symbol,
+			TokenType:      &tag,
+			OfficialSymbol: v.OfficialSymbol,
+			LogoUrl:        v.LogoUrl,
+			ProjectUrl:     v.ProjectUrl,
+			CoingeckoId:    v.CoingeckoId,
+			Bridge:         extensions["bridge"],
+			Extensions:     extensions,
+		}
+		if coin.OfficialSymbol == "" {
+			coin.OfficialSymbol = coin.Symbol
+		}
+		for _, t := range v.Tags {
+			coin.Tags = appendCoinTag(coin.Tags, types.CoinTag(t))
+		}
+		if coin.Bridge != "" {
+			coin.Tags = appendCoinTag(coin.Tags, types.WrappedCoinTag)
+		}
+		coinList = append(coinList, coin)
+	}
 	return coinList, nil
 }
 
+func appendCoinTag(tags []types.CoinTag, tag types.CoinTag) []types.CoinTag {
+	for _, t := range tags {
+		if t == tag {
+			return tags
+		}
+	}
+	return append(tags, tag)
+}
+
 type coinInfo struct {
-	Name      string `json:"name"`
-	Symbol    string `json:"symbol"`
-	Decimals  int64  `json:"decimals"`
-	LogoUrl   string `json:"logo_url"`
-	TokenType struct {
+	Name           string   `json:"name"`
+	Symbol         string   `json:"symbol"`
+	OfficialSymbol string   `json:"official_symbol"`
+	CoingeckoId    string   `json:"coingecko_id"`
+	Decimals       int64    `json:"decimals"`
+	LogoUrl        string   `json:"logo_url"`
+	ProjectUrl     string   `json:"project_url"`
+	Tags           []string `json:"tags"`
+	TokenType      struct {
 		Type string `json:"type"`
 	} `json:"token_type"`
+	Extensions struct {
+		Data [][]string `json:"data"`
+	} `json:"extensions"`
 }
@@ -0,0 +1,156 @@
+package contract
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coming-chat/go-aptos/aptosclient"
+	"github.com/omnibtc/go-hippo-sdk/types"
+)
+
+const testCoinList = `[{"name": "Aptos Coin", "symbol": "APT", "decimals": 8,
+	"token_type": {"type": "0x1::aptos_coin::AptosCoin"}}]`
+
+func TestEmbeddedCoinListApp(t *testing.T) {
+	list, err := NewEmbeddedCoinListApp().QueryFetchFullList()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) == 0 {
+		t.Errorf("embedded coin list is empty")
+	}
+}
+
+func TestRemoteCoinListApp(t *testing.T) {
+	downloads := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		downloads++
+		w.Header().Set("ETag", `"v1"`)
+		_, _ = w.Write([]byte(testCoinList))
+	}))
+	defer server.Close()
+
+	app := NewRemoteCoinListApp(server.URL, nil)
+	for i := 0; i < 2; i++ {
+		list, err := app.QueryFetchFullList()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(list) != 1 || list[0].TokenType.GetFullName() != "0x1::aptos_coin::AptosCoin" {
+			t.Errorf("QueryFetchFullList() = %v", list)
+		}
+	}
+	if downloads != 1 {
+		t.Errorf("downloads = %d, want 1", downloads)
+	}
+}
+
+func TestMergedCoinListApp(t *testing.T) {
+	reader, err := NewReaderCoinListApp(strings.NewReader(testCoinList))
+	if err != nil {
+		t.Fatal(err)
+	}
+	custom := NewCustomCoinListApp([]types.CoinInfo{
+		{Symbol: "APT2", TokenType: &types.StructTag{Address: "0x0001", Module: "aptos_coin", Name: "AptosCoin"}},
+		{Symbol: "USDC", TokenType: &types.StructTag{Address: "0x2", Module: "usdc", Name: "USDC"}},
+	})
+	list, err := NewMergedCoinListApp(reader, custom).QueryFetchFullList()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || list[0].Symbol != "APT" || list[1].Symbol != "USDC" {
+		t.Errorf("QueryFetchFullList() = %v", list)
+	}
+}
+
+func TestOnChainCoinListApp(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1" {
+			_, _ = w.Write([]byte(`{"chain_id":1,"ledger_version":"1","ledger_timestamp":"1","block_height":"1"}`))
+			return
+		}
+		requests++
+		if r.URL.Path != "/v1/accounts/0x2/resource/0x1::coin::CoinInfo<0x2::moon::MOON>" {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"message":"not found","error_code":"resource_not_found"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"type":"0x1::coin::CoinInfo<0x2::moon::MOON>",
+			"data":{"name":"Moon","symbol":"MOON","decimals":6,"supply":{"vec":[]}}}`))
+	}))
+	defer server.Close()
+	client, err := aptosclient.Dial(context.Background(), server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app := NewOnChainCoinListApp(client, NewCustomCoinListApp(nil))
+	for i := 0; i < 2; i++ {
+		coin, err := app.DiscoverCoinInfo(&types.StructTag{Address: "0x0002", Module: "moon", Name: "MOON"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if coin.Symbol != "MOON" || coin.Decimals != 6 || !coin.Unverified {
+			t.Errorf("DiscoverCoinInfo() = %+v", coin)
+		}
+	}
+	if requests != 1 {
+		t.Errorf("requests = %d, want 1", requests)
+	}
+	// the failure is cached until the ttl expires
+	now := time.Now()
+	app.now = func() time.Time { return now }
+	sun := &types.StructTag{Address: "0x2", Module: "moon", Name: "SUN"}
+	for i := 0; i < 2; i++ {
+		if _, err := app.DiscoverCoinInfo(sun); err == nil {
+			t.Errorf("DiscoverCoinInfo() of missing coin, want error")
+		}
+	}
+	if requests != 2 {
+		t.Errorf("requests = %d, want 2", requests)
+	}
+	now = now.Add(DefaultDiscoverFailureTTL)
+	if _, err := app.DiscoverCoinInfo(sun); err == nil || requests != 3 {
+		t.Errorf("DiscoverCoinInfo() after ttl = %v, requests = %d, want error and 3", err, requests)
+	}
+	list, err := app.QueryFetchFullList()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].Symbol != "MOON" {
+		t.Errorf("QueryFetchFullList() = %v", list)
+	}
+}
+
+func TestParseCoinList(t *testing.T) {
+	list, err := ParseCoinList([]byte(`[{"name": "Pool LP", "symbol": "LP", "decimals": 6, "tags": ["lp", "custom"],
+		"token_type": {"type": "0x3::swap::LPToken<0x1::aptos_coin::AptosCoin, 0x2::usdc::USDC>"},
+		"extensions": {"data": [["bridge", "portal"], ["website", "https://example.com"]]}},
+		{"name": "Help", "symbol": "HELP", "decimals": 8, "token_type": {"type": "0x4::coin::T"}},
+		{"name": "USD Coin", "symbol": "USDC", "decimals": 6, "token_type": {"type": "0x5::usdc::ALPHA_LP"}}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	coin := list[0]
+	if coin.OfficialSymbol != "LP" || coin.Bridge != "portal" || coin.Extensions["website"] != "https://example.com" {
+		t.Errorf("ParseCoinList() = %+v", coin)
+	}
+	if !coin.HasTag(types.LPCoinTag) || !coin.HasTag("custom") || !coin.HasTag(types.WrappedCoinTag) || len(coin.Tags) != 3 {
+		t.Errorf("ParseCoinList() tags = %v", coin.Tags)
+	}
+	// nothing is guessed from the type or symbol of untagged coins
+	for _, coin := range list[1:] {
+		if len(coin.Tags) != 0 || coin.Bridge != "" {
+			t.Errorf("ParseCoinList() %s tags = %v, bridge = %q, want none", coin.Symbol, coin.Tags, coin.Bridge)
+		}
+	}
+}
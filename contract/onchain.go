@@ -0,0 +1,152 @@
+package contract
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/coming-chat/go-aptos/aptosclient"
+	"github.com/omnibtc/go-hippo-sdk/types"
+)
+
+// CoinDiscoverer is a CoinListApp which can find coins not in its list
+type CoinDiscoverer interface {
+	CoinListApp
+	DiscoverCoinInfo(tokenType types.TokenType) (types.CoinInfo, error)
+}
+
+// DefaultDiscoverFailureTTL is how long a coin which failed to be discovered is not requested again
+const DefaultDiscoverFailureTTL = 10 * time.Minute
+
+// OnChainCoinListApp is the list of a base app plus coins discovered from their 0x1::coin::CoinInfo resource,
+// discovered coins are marked unverified and cached, failures are cached for a while
+type OnChainCoinListApp struct {
+	client *aptosclient.RestClient
+	base   CoinListApp
+
+	lock       sync.Mutex
+	discovered []types.CoinInfo
+	byFullName map[string]types.CoinInfo
+	failed     map[string]discoverFailure
+	failureTTL time.Duration
+	now        func() time.Time
+}
+
+// discoverFailure is the cached error of a coin until expiry
+type discoverFailure struct {
+	err    error
+	expiry time.Time
+}
+
+func NewOnChainCoinListApp(client *aptosclient.RestClient, base CoinListApp) *OnChainCoinListApp {
+	return &OnChainCoinListApp{
+		client:     client,
+		base:       base,
+		byFullName: make(map[string]types.CoinInfo),
+		failed:     make(map[string]discoverFailure),
+		failureTTL: DefaultDiscoverFailureTTL,
+		now:        time.Now,
+	}
+}
+
+// SetFailureTTL set how long a failed discovery is cached, 0 disables the cache
+func (c *OnChainCoinListApp) SetFailureTTL(ttl time.Duration) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.failureTTL = ttl
+	c.failed = make(map[string]discoverFailure)
+}
+
+// QueryFetchFullList return the list of base app, followed by discovered coins not in it
+func (c *OnChainCoinListApp) QueryFetchFullList() ([]types.CoinInfo, error) {
+	coinList, err := c.base.QueryFetchFullList()
+	if err != nil {
+		return nil, err
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if len(c.discovered) == 0 {
+		return coinList, nil
+	}
+	known := make(map[string]bool, len(coinList))
+	for _, coin := range coinList {
+		known[types.CanonicalTypeName(coin.TokenType.GetFullName())] = true
+	}
+	result := append(make([]types.CoinInfo, 0, len(coinList)+len(c.discovered)), coinList...)
+	for _, coin := range c.discovered {
+		if !known[coin.TokenType.GetFullName()] {
+			result = append(result, coin)
+		}
+	}
+	return result, nil
+}
+
+// DiscoverCoinInfo read the CoinInfo resource of tokenType at its module address,
+// a failed coin returns the cached error until the failure TTL expires
+func (c *OnChainCoinListApp) DiscoverCoinInfo(tokenType types.TokenType) (types.CoinInfo, error) {
+	fullName := types.CanonicalTypeName(tokenType.GetFullName())
+	c.lock.Lock()
+	coin, ok := c.byFullName[fullName]
+	failure, failed := c.failed[fullName]
+	if failed && !c.now().Before(failure.expiry) {
+		delete(c.failed, fullName)
+		failed = false
+	}
+	c.lock.Unlock()
+	if ok {
+		return coin, nil
+	}
+	if failed {
+		return types.CoinInfo{}, failure.err
+	}
+
+	coin, err := c.fetchCoinInfo(fullName)
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if err != nil {
+		if c.failureTTL > 0 {
+			c.failed[fullName] = discoverFailure{err: err, expiry: c.now().Add(c.failureTTL)}
+		}
+		return types.CoinInfo{}, err
+	}
+	if cached, ok := c.byFullName[fullName]; ok {
+		return cached, nil
+	}
+	c.byFullName[fullName] = coin
+	c.discovered = append(c.discovered, coin)
+	return coin, nil
+}
+
+func (c *OnChainCoinListApp) fetchCoinInfo(fullName string) (types.CoinInfo, error) {
+	tag, err := types.ParseMoveStructTag(fullName)
+	if err != nil {
+		return types.CoinInfo{}, err
+	}
+	resource, err := c.client.GetAccountResource(tag.Address, fmt.Sprintf("0x1::coin::CoinInfo<%s>", fullName), 0)
+	if err != nil {
+		return types.CoinInfo{}, fmt.Errorf("coin info of %s: %w", fullName, err)
+	}
+	data, err := json.Marshal(resource.Data)
+	if err != nil {
+		return types.CoinInfo{}, err
+	}
+	var info onChainCoinInfo
+	if err = json.Unmarshal(data, &info); err != nil {
+		return types.CoinInfo{}, fmt.Errorf("decode coin info of %s: %w", fullName, err)
+	}
+	return types.CoinInfo{
+		Name:       info.Name,
+		Decimals:   info.Decimals,
+		Symbol:     info.Symbol,
+		TokenType:  &tag,
+		Unverified: true,
+	}, nil
+}
+
+// onChainCoinInfo is the json layout of 0x1::coin::CoinInfo
+type onChainCoinInfo struct {
+	Name     string `json:"name"`
+	Symbol   string `json:"symbol"`
+	Decimals int    `json:"decimals"`
+}
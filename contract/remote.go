@@ -0,0 +1,67 @@
+package contract
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/omnibtc/go-hippo-sdk/types"
+)
+
+// RemoteCoinListApp fetch a hippo coin list json over http,
+// the list is cached with its ETag and only downloaded again when it has changed
+type RemoteCoinListApp struct {
+	url    string
+	client *http.Client
+
+	lock     sync.Mutex
+	etag     string
+	coinList []types.CoinInfo
+}
+
+// NewRemoteCoinListApp fetch url with client, http.DefaultClient if nil
+func NewRemoteCoinListApp(url string, client *http.Client) CoinListApp {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &RemoteCoinListApp{url: url, client: client}
+}
+
+func (c *RemoteCoinListApp) QueryFetchFullList() ([]types.CoinInfo, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	req, err := http.NewRequest(http.MethodGet, c.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if c.etag != "" {
+		req.Header.Set("If-None-Match", c.etag)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		if c.coinList == nil {
+			return nil, fmt.Errorf("coin list %s not modified but never fetched", c.url)
+		}
+		return c.coinList, nil
+	case http.StatusOK:
+	default:
+		return nil, fmt.Errorf("fetch coin list %s: %s", c.url, resp.Status)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	coinList, err := ParseCoinList(data)
+	if err != nil {
+		return nil, err
+	}
+	c.etag = resp.Header.Get("ETag")
+	c.coinList = coinList
+	return coinList, nil
+}
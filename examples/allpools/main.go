@@ -39,18 +39,22 @@ func main() {
 	})
 	panicErr(err)
 
-	aggr := aggregator.NewTradeAggregator(
-		contract.App{
-			CoinList: coinListApp,
-		},
+	auxProvider, err := auxamm.NewPoolProvider(client, auxPoolAddress, coinListClient, auxPoolAddress)
+	panicErr(err)
+	pontemProvider, err := pontem.NewPoolProvider(client, pontemAddress, coinListClient, pontemAddress)
+	panicErr(err)
+	pancakeProvider, err := pancake.NewPoolProvider(client, pancakePoolAddress, coinListClient, pancakePoolAddress)
+	panicErr(err)
+	aggr := aggregator.NewTradeAggregatorWithCoinList(
+		coinListClient,
 		types.SimulationKeys{},
 		[]base.TradingPoolProvider{
 			basiq.NewPoolProvider(client, basiqPoolAddress, coinListClient),
-			auxamm.NewPoolProvider(client, auxPoolAddress, coinListClient, auxPoolAddress),
-			pontem.NewPoolProvider(client, pontemAddress, coinListClient, pontemAddress),
+			auxProvider,
+			pontemProvider,
 			aptosswap.NewPoolProvider(client, aptosPoolAddress, coinListClient),
 			anime.NewPoolProvider(client, animePoolAddress, coinListClient),
-			pancake.NewPoolProvider(client, pancakePoolAddress, coinListClient, pancakePoolAddress),
+			pancakeProvider,
 			obric.NewPoolProvider(client, obricPoolAddress, coinListClient),
 		},
 	)
@@ -63,7 +67,7 @@ func main() {
 		panic("coiny not found")
 	}
 	inputAmount := big.NewInt(100000000)
-	quotes, err := aggr.GetQuotes(inputAmount, coinX, coinY, 3, false, false)
+	quotes, err := aggr.GetQuotes(inputAmount, coinX, coinY, aggregator.RouteOptions{MaxSteps: 3})
 	panicErr(err)
 
 	fmt.Printf("quote size: %d\n", len(quotes))
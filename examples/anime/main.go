@@ -27,10 +27,8 @@ func main() {
 	})
 	panicErr(err)
 
-	aggr := aggregator.NewTradeAggregator(
-		contract.App{
-			CoinList: coinListApp,
-		},
+	aggr := aggregator.NewTradeAggregatorWithCoinList(
+		coinListClient,
 		types.SimulationKeys{},
 		[]base.TradingPoolProvider{anime.NewPoolProvider(client, poolAddress, coinListClient)},
 	)
@@ -43,7 +41,7 @@ func main() {
 		panic("coiny not found")
 	}
 	inputAmount := big.NewInt(100000000)
-	quotes, err := aggr.GetQuotes(inputAmount, coinX, coinY, 3, false, false)
+	quotes, err := aggr.GetQuotes(inputAmount, coinX, coinY, aggregator.RouteOptions{MaxSteps: 3})
 	panicErr(err)
 
 	fmt.Printf("quote size: %d\n", len(quotes))
@@ -27,12 +27,12 @@ func main() {
 	})
 	panicErr(err)
 
-	aggr := aggregator.NewTradeAggregator(
-		contract.App{
-			CoinList: coinListApp,
-		},
+	poolProvider, err := auxamm.NewPoolProvider(client, poolAddress, coinListClient, poolAddress)
+	panicErr(err)
+	aggr := aggregator.NewTradeAggregatorWithCoinList(
+		coinListClient,
 		types.SimulationKeys{},
-		[]base.TradingPoolProvider{auxamm.NewPoolProvider(client, poolAddress, coinListClient, poolAddress)},
+		[]base.TradingPoolProvider{poolProvider},
 	)
 	coinX, ok := coinListClient.GetCoinInfoByFullName("0x1::aptos_coin::AptosCoin")
 	if !ok {
@@ -43,7 +43,7 @@ func main() {
 		panic("coiny not found")
 	}
 	inputAmount := big.NewInt(100000000)
-	quotes, err := aggr.GetQuotes(inputAmount, coinX, coinY, 3, false, false)
+	quotes, err := aggr.GetQuotes(inputAmount, coinX, coinY, aggregator.RouteOptions{MaxSteps: 3})
 	panicErr(err)
 
 	fmt.Printf("quote size: %d\n", len(quotes))
@@ -4,12 +4,12 @@ go 1.18
 
 require (
 	github.com/coming-chat/go-aptos v0.0.0-20221103071223-ffb02e9c1df9
+	github.com/omnibtc/go-aptos-liquidswap v0.0.0-20221008022026-6c4acdaf4ab1
 	github.com/shopspring/decimal v1.3.1
 )
 
 require (
 	github.com/coming-chat/lcs v0.0.0-20220829063658-0fa8432d2bdf // indirect
-	github.com/omnibtc/go-aptos-liquidswap v0.0.0-20221008022026-6c4acdaf4ab1 // indirect
 	github.com/tyler-smith/go-bip39 v1.1.0 // indirect
 	golang.org/x/crypto v0.0.0-20200622213623-75b288015ac9 // indirect
 	golang.org/x/sys v0.0.0-20190412213103-97732733099d // indirect
@@ -0,0 +1,80 @@
+package types
+
+import (
+	"fmt"
+	"strings"
+)
+
+// addressLength is the number of hex digits of a long form address
+const addressLength = 64
+
+// NormalizeAddress return the canonical form of an account address:
+// special addresses 0x0 to 0xf are short, eg. 0x1, the others are 0x and 64 lowercase hex digits.
+// Addresses which are not hex are returned unchanged.
+func NormalizeAddress(address string) string {
+	normalized, err := ParseAddress(address)
+	if err != nil {
+		return address
+	}
+	return normalized
+}
+
+// ParseAddress parse an address with or without 0x and leading zeros into its canonical form
+func ParseAddress(address string) (string, error) {
+	if isCanonicalAddress(address) {
+		return address, nil
+	}
+	digits := address
+	if strings.HasPrefix(digits, "0x") || strings.HasPrefix(digits, "0X") {
+		digits = digits[2:]
+	}
+	if len(digits) == 0 || len(digits) > addressLength {
+		return "", fmt.Errorf("invalid address: %s", address)
+	}
+	for i := 0; i < len(digits); i++ {
+		if !isHexDigit(digits[i]) {
+			return "", fmt.Errorf("invalid address: %s", address)
+		}
+	}
+	digits = strings.ToLower(strings.TrimLeft(digits, "0"))
+	if len(digits) <= 1 {
+		if digits == "" {
+			digits = "0"
+		}
+		return "0x" + digits, nil
+	}
+	return "0x" + strings.Repeat("0", addressLength-len(digits)) + digits, nil
+}
+
+// isCanonicalAddress is a fast path of ParseAddress for addresses which are already canonical
+func isCanonicalAddress(address string) bool {
+	if !strings.HasPrefix(address, "0x") {
+		return false
+	}
+	digits := address[2:]
+	switch len(digits) {
+	case 1:
+		return isLowerHexDigit(digits[0])
+	case addressLength:
+		// long form of special addresses is not canonical
+		if strings.HasPrefix(digits, strings.Repeat("0", addressLength-1)) {
+			return false
+		}
+		for i := 0; i < len(digits); i++ {
+			if !isLowerHexDigit(digits[i]) {
+				return false
+			}
+		}
+		return true
+	default:
+		return false
+	}
+}
+
+func isHexDigit(c byte) bool {
+	return isLowerHexDigit(c) || (c >= 'A' && c <= 'F')
+}
+
+func isLowerHexDigit(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f')
+}
@@ -0,0 +1,48 @@
+package types
+
+import "testing"
+
+func TestParseAddress(t *testing.T) {
+	long := "0x5e156f1207d0ebfa19a9eeff00d62a282278fb8719f4fab3a586a0a2c0fffbea"
+	tests := []struct {
+		address string
+		want    string
+		wantErr bool
+	}{
+		{"0x1", "0x1", false},
+		{"0x0000000000000000000000000000000000000000000000000000000000000001", "0x1", false},
+		{"1", "0x1", false},
+		{"0x0", "0x0", false},
+		{"0xA", "0xa", false},
+		{"0x10", "0x0000000000000000000000000000000000000000000000000000000000000010", false},
+		{long, long, false},
+		{"0x5E156F1207D0EBFA19A9EEFF00D62A282278FB8719F4FAB3A586A0A2C0FFFBEA", long, false},
+		{"0x00abc", "0x0000000000000000000000000000000000000000000000000000000000000abc", false},
+		{"0x", "", true},
+		{"0xg1", "", true},
+		{"0x" + long[2:] + "1", "", true},
+	}
+	for _, tt := range tests {
+		got, err := ParseAddress(tt.address)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseAddress(%q) error = %v, wantErr %v", tt.address, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseAddress(%q) = %q, want %q", tt.address, got, tt.want)
+		}
+	}
+}
+
+func TestCanonicalTypeName(t *testing.T) {
+	want := "0x1::coin::CoinStore<0x0000000000000000000000000000000000000000000000000000000000000abc::coin::T, vector<u8>>"
+	for _, name := range []string{
+		want,
+		"0x1::coin::CoinStore<0xabc::coin::T,vector<u8>>",
+		"0x0000000000000000000000000000000000000000000000000000000000000001::coin::CoinStore< 0xABC::coin::T ,  vector<u8> >",
+	} {
+		if got := CanonicalTypeName(name); got != want {
+			t.Errorf("CanonicalTypeName(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
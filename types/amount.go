@@ -0,0 +1,94 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"regexp"
+	"strings"
+
+	"github.com/shopspring/decimal"
+)
+
+// Rounding is how an amount with more decimals than the coin is rounded to base units
+type Rounding int
+
+const (
+	// RoundDown round toward zero, eg. for the amount a user receives
+	RoundDown Rounding = iota
+	// RoundUp round away from zero, eg. for the amount a user pays
+	RoundUp
+	// RoundHalfUp round half away from zero
+	RoundHalfUp
+	// RoundHalfEven round half to the even digit
+	RoundHalfEven
+)
+
+// amountReg match plain decimal amounts, exponents like 1e8 are not amounts a user types
+var amountReg *regexp.Regexp = regexp.MustCompile(`^-?[0-9]+(\.[0-9]+)?$`)
+
+// maxAmount is the max u64 of Move coin amounts
+var maxAmount = new(big.Int).SetUint64(math.MaxUint64)
+
+// Unit return the base units of one whole coin, 10^decimals
+func (c CoinInfo) Unit() *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(c.Decimals)), nil)
+}
+
+// ToBaseUnits convert a whole coin amount, eg. 1.5 APT, to base units, eg. 150000000
+func (c CoinInfo) ToBaseUnits(amount decimal.Decimal, rounding Rounding) *big.Int {
+	places := int32(c.Decimals)
+	switch rounding {
+	case RoundUp:
+		amount = amount.RoundUp(places)
+	case RoundHalfUp:
+		amount = amount.Round(places)
+	case RoundHalfEven:
+		amount = amount.RoundBank(places)
+	default:
+		amount = amount.RoundDown(places)
+	}
+	return amount.Shift(places).BigInt()
+}
+
+// FromBaseUnits convert base units to whole coin amount
+func (c CoinInfo) FromBaseUnits(amount *big.Int) decimal.Decimal {
+	return decimal.NewFromBigInt(amount, int32(-c.Decimals))
+}
+
+// ParseAmount parse a whole coin amount typed by a user, eg. "1.5", into base units.
+// It is an error if the amount is not a plain decimal, is negative, has more decimals than the coin or overflows u64.
+func (c CoinInfo) ParseAmount(input string) (*big.Int, error) {
+	s := strings.TrimSpace(input)
+	if s == "" {
+		return nil, errors.New("empty amount")
+	}
+	if !amountReg.MatchString(s) {
+		return nil, fmt.Errorf("invalid amount: %s", input)
+	}
+	amount, err := decimal.NewFromString(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid amount: %s", input)
+	}
+	if amount.IsNegative() {
+		return nil, fmt.Errorf("negative amount: %s", input)
+	}
+	units := amount.Shift(int32(c.Decimals))
+	if !units.IsInteger() {
+		return nil, fmt.Errorf("amount %s has more than %d decimals of %s", input, c.Decimals, c.Symbol)
+	}
+	result := units.BigInt()
+	if result.Cmp(maxAmount) > 0 {
+		return nil, fmt.Errorf("amount %s is too large for %s", input, c.Symbol)
+	}
+	return result, nil
+}
+
+// FormatAmount format base units as whole coin amount with symbol, eg. 1.5 APT
+func (c CoinInfo) FormatAmount(amount *big.Int) string {
+	if amount == nil {
+		return "- " + c.Symbol
+	}
+	return c.FromBaseUnits(amount).String() + " " + c.Symbol
+}
@@ -0,0 +1,76 @@
+package types
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+var testAPT = CoinInfo{Symbol: "APT", Decimals: 8, TokenType: &StructTag{Address: "0x1", Module: "aptos_coin", Name: "AptosCoin"}}
+
+func TestCoinInfo_ToBaseUnits(t *testing.T) {
+	usdc := CoinInfo{Symbol: "USDC", Decimals: 2}
+	tests := []struct {
+		amount   string
+		rounding Rounding
+		want     int64
+	}{
+		{"1.5", RoundDown, 150},
+		{"1.239", RoundDown, 123},
+		{"1.231", RoundUp, 124},
+		{"1.235", RoundHalfUp, 124},
+		{"1.225", RoundHalfEven, 122},
+		{"1.235", RoundHalfEven, 124},
+	}
+	for _, tt := range tests {
+		got := usdc.ToBaseUnits(decimal.RequireFromString(tt.amount), tt.rounding)
+		if got.Int64() != tt.want {
+			t.Errorf("ToBaseUnits(%s, %d) = %s, want %d", tt.amount, tt.rounding, got, tt.want)
+		}
+	}
+	if got := usdc.FromBaseUnits(big.NewInt(150)); !got.Equal(decimal.RequireFromString("1.5")) {
+		t.Errorf("FromBaseUnits() = %s", got)
+	}
+}
+
+func TestCoinInfo_ParseAmount(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{"1.5", "150000000", false},
+		{" 0.00000001 ", "1", false},
+		{"2.100000000", "210000000", false},
+		{"100", "10000000000", false},
+		{"0.000000001", "", true},
+		{"-1", "", true},
+		{"", "", true},
+		{"1.2.3", "", true},
+		{"1e100000", "", true},
+		{"1.", "", true},
+		{".5", "", true},
+		{"184467440737.09551615", "18446744073709551615", false},
+		{"184467440737.09551616", "", true},
+	}
+	for _, tt := range tests {
+		got, err := testAPT.ParseAmount(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseAmount(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			continue
+		}
+		if err == nil && got.String() != tt.want {
+			t.Errorf("ParseAmount(%q) = %s, want %s", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestCoinInfo_FormatAmount(t *testing.T) {
+	if got := testAPT.FormatAmount(big.NewInt(150000000)); got != "1.5 APT" {
+		t.Errorf("FormatAmount() = %q", got)
+	}
+	if got := testAPT.FormatAmount(big.NewInt(1)); got != "0.00000001 APT" {
+		t.Errorf("FormatAmount() = %q", got)
+	}
+}
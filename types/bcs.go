@@ -0,0 +1,275 @@
+package types
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// BCS variant index of each TypeTag kind, same as aptos TypeTag enum
+const (
+	bcsTagBool uint64 = iota
+	bcsTagU8
+	bcsTagU64
+	bcsTagU128
+	bcsTagAddress
+	bcsTagSigner
+	bcsTagVector
+	bcsTagStruct
+	bcsTagU16
+	bcsTagU32
+	bcsTagU256
+)
+
+var atomicBcsTags = map[string]uint64{
+	string(Bool):    bcsTagBool,
+	string(U8):      bcsTagU8,
+	string(U16):     bcsTagU16,
+	string(U32):     bcsTagU32,
+	string(U64):     bcsTagU64,
+	string(U128):    bcsTagU128,
+	string(U256):    bcsTagU256,
+	string(Address): bcsTagAddress,
+	string(Signer):  bcsTagSigner,
+}
+
+// MarshalBCS encode the type tag as aptos TypeTag, type params and references have no BCS form
+func (t TypeTag) MarshalBCS() ([]byte, error) {
+	return t.appendBCS(nil)
+}
+
+// UnmarshalBCS decode an aptos TypeTag
+func (t *TypeTag) UnmarshalBCS(data []byte) error {
+	d := &bcsDecoder{data: data}
+	tag, err := d.typeTag()
+	if err != nil {
+		return err
+	}
+	if len(d.data) > 0 {
+		return errors.New("bcs: trailing bytes after type tag")
+	}
+	*t = tag
+	return nil
+}
+
+// MarshalBCS encode the struct tag as aptos StructTag
+func (t StructTag) MarshalBCS() ([]byte, error) {
+	return t.appendBCS(nil)
+}
+
+// UnmarshalBCS decode an aptos StructTag
+func (t *StructTag) UnmarshalBCS(data []byte) error {
+	d := &bcsDecoder{data: data}
+	tag, err := d.structTag()
+	if err != nil {
+		return err
+	}
+	if len(d.data) > 0 {
+		return errors.New("bcs: trailing bytes after struct tag")
+	}
+	*t = *tag
+	return nil
+}
+
+// MarshalJSON encode the type tag as its full name
+func (t TypeTag) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.GetFullName())
+}
+
+func (t *TypeTag) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err != nil {
+		return err
+	}
+	tag, err := ParseTypeTag(name)
+	if err != nil {
+		return err
+	}
+	*t = tag
+	return nil
+}
+
+// MarshalJSON encode the struct tag as its full name
+func (t StructTag) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.GetFullName())
+}
+
+func (t *StructTag) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err != nil {
+		return err
+	}
+	tag, err := ParseMoveStructTag(name)
+	if err != nil {
+		return err
+	}
+	*t = tag
+	return nil
+}
+
+func (t TypeTag) appendBCS(b []byte) ([]byte, error) {
+	switch {
+	case t.AtomicTypeTag != nil:
+		variant, ok := atomicBcsTags[t.AtomicTypeTag.Name]
+		if !ok {
+			return nil, fmt.Errorf("bcs: unknown type %s", t.AtomicTypeTag.Name)
+		}
+		return appendUleb128(b, variant), nil
+	case t.VectorTag != nil:
+		return t.VectorTag.TypeParam.appendBCS(appendUleb128(b, bcsTagVector))
+	case t.StructTag != nil:
+		return t.StructTag.appendBCS(appendUleb128(b, bcsTagStruct))
+	default:
+		return nil, fmt.Errorf("bcs: type %s has no bcs form", t.GetFullName())
+	}
+}
+
+func (t StructTag) appendBCS(b []byte) ([]byte, error) {
+	address, err := addressBytes(t.Address)
+	if err != nil {
+		return nil, err
+	}
+	b = append(b, address...)
+	b = appendBCSString(b, t.Module)
+	b = appendBCSString(b, t.Name)
+	b = appendUleb128(b, uint64(len(t.TypeParams)))
+	for _, param := range t.TypeParams {
+		if b, err = param.appendBCS(b); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+// addressBytes return the 32 bytes of address
+func addressBytes(address string) ([]byte, error) {
+	accountAddress, err := ParseAccountAddress(address)
+	if err != nil {
+		return nil, err
+	}
+	return accountAddress[:], nil
+}
+
+func appendBCSString(b []byte, s string) []byte {
+	b = appendUleb128(b, uint64(len(s)))
+	return append(b, s...)
+}
+
+func appendUleb128(b []byte, v uint64) []byte {
+	for v >= 0x80 {
+		b = append(b, byte(v)|0x80)
+		v >>= 7
+	}
+	return append(b, byte(v))
+}
+
+// bcsMaxDepth limit the nesting of decoded type tags
+const bcsMaxDepth = 64
+
+type bcsDecoder struct {
+	data  []byte
+	depth int
+}
+
+func (d *bcsDecoder) uleb128() (uint64, error) {
+	var v uint64
+	for shift := uint(0); shift < 64; shift += 7 {
+		if len(d.data) == 0 {
+			return 0, errors.New("bcs: unexpected end of data")
+		}
+		c := d.data[0]
+		d.data = d.data[1:]
+		v |= uint64(c&0x7f) << shift
+		if c < 0x80 {
+			return v, nil
+		}
+	}
+	return 0, errors.New("bcs: uleb128 overflow")
+}
+
+func (d *bcsDecoder) bytes(n uint64) ([]byte, error) {
+	if uint64(len(d.data)) < n {
+		return nil, errors.New("bcs: unexpected end of data")
+	}
+	b := d.data[:n]
+	d.data = d.data[n:]
+	return b, nil
+}
+
+func (d *bcsDecoder) string() (string, error) {
+	n, err := d.uleb128()
+	if err != nil {
+		return "", err
+	}
+	b, err := d.bytes(n)
+	return string(b), err
+}
+
+func (d *bcsDecoder) typeTag() (TypeTag, error) {
+	d.depth++
+	defer func() { d.depth-- }()
+	if d.depth > bcsMaxDepth {
+		return TypeTag{}, errors.New("bcs: type tag nested too deep")
+	}
+	variant, err := d.uleb128()
+	if err != nil {
+		return TypeTag{}, err
+	}
+	switch variant {
+	case bcsTagVector:
+		param, err := d.typeTag()
+		if err != nil {
+			return TypeTag{}, err
+		}
+		return TypeTag{VectorTag: &VectorTag{TypeParam: param}}, nil
+	case bcsTagStruct:
+		tag, err := d.structTag()
+		if err != nil {
+			return TypeTag{}, err
+		}
+		return TypeTag{StructTag: tag}, nil
+	}
+	for name, v := range atomicBcsTags {
+		if v == variant {
+			return TypeTag{AtomicTypeTag: &AtomicTypeTag{Name: name}}, nil
+		}
+	}
+	return TypeTag{}, fmt.Errorf("bcs: unknown type tag variant %d", variant)
+}
+
+func (d *bcsDecoder) structTag() (*StructTag, error) {
+	address, err := d.bytes(addressLength / 2)
+	if err != nil {
+		return nil, err
+	}
+	module, err := d.string()
+	if err != nil {
+		return nil, err
+	}
+	name, err := d.string()
+	if err != nil {
+		return nil, err
+	}
+	n, err := d.uleb128()
+	if err != nil {
+		return nil, err
+	}
+	if n > uint64(len(d.data)) {
+		return nil, errors.New("bcs: unexpected end of data")
+	}
+	params := make([]TypeTag, 0, n)
+	for i := uint64(0); i < n; i++ {
+		param, err := d.typeTag()
+		if err != nil {
+			return nil, err
+		}
+		params = append(params, param)
+	}
+	return &StructTag{
+		Address:    NormalizeAddress("0x" + hex.EncodeToString(address)),
+		Module:     module,
+		Name:       name,
+		TypeParams: params,
+	}, nil
+}
@@ -0,0 +1,123 @@
+package types
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestParseTypeTag(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    string
+		wantErr bool
+	}{
+		{"u16", "u16", false},
+		{"u32", "u32", false},
+		{"u256", "u256", false},
+		{"vector<u256>", "vector<u256>", false},
+		{"0x1::m::S<u16,u32>", "0x1::m::S<u16, u32>", false},
+		{"$tv12", "$tv12", false},
+		{"vector<$tv0>", "vector<$tv0>", false},
+		{"&signer", "&signer", false},
+		{"& mut  0x1::coin::Coin<$tv0>", "&mut 0x1::coin::Coin<$tv0>", false},
+		{"u8foo", "", true},
+		{"vector<u8foo>", "", true},
+		{"&&signer", "", true},
+		{"$tv", "", true},
+	}
+	for _, tt := range tests {
+		got, err := ParseTypeTag(tt.name)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseTypeTag(%q) error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if err == nil && got.GetFullName() != tt.want {
+			t.Errorf("ParseTypeTag(%q) = %q, want %q", tt.name, got.GetFullName(), tt.want)
+		}
+	}
+	if tag, _ := ParseTypeTag("$tv12"); tag.TypeParamIdx == nil || tag.TypeParamIdx.ParamIdx != 12 {
+		t.Errorf("ParseTypeTag($tv12) = %+v, want param 12", tag.TypeParamIdx)
+	}
+}
+
+func TestTypeTag_BCS(t *testing.T) {
+	tag, err := ParseTypeTag("0x1::aptos_coin::AptosCoin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := tag.MarshalBCS()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, _ := hex.DecodeString("07" + "0000000000000000000000000000000000000000000000000000000000000001" +
+		"0a" + hex.EncodeToString([]byte("aptos_coin")) + "09" + hex.EncodeToString([]byte("AptosCoin")) + "00")
+	if !bytes.Equal(data, want) {
+		t.Errorf("MarshalBCS() = %x, want %x", data, want)
+	}
+
+	for _, name := range []string{
+		"u8", "u16", "u32", "u64", "u128", "u256", "bool", "address", "signer",
+		"vector<vector<u8>>",
+		"0x1::coin::CoinStore<0x5e156f1207d0ebfa19a9eeff00d62a282278fb8719f4fab3a586a0a2c0fffbea::coin::T>",
+		"0xabc::m::S<u16, vector<0x1::m::T<u256>>, address>",
+	} {
+		tag, err := ParseTypeTag(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := tag.MarshalBCS()
+		if err != nil {
+			t.Fatalf("%s MarshalBCS() error = %v", name, err)
+		}
+		var got TypeTag
+		if err := got.UnmarshalBCS(data); err != nil {
+			t.Fatalf("%s UnmarshalBCS() error = %v", name, err)
+		}
+		if !reflect.DeepEqual(got, tag) {
+			t.Errorf("BCS round trip of %s = %s", name, got.GetFullName())
+		}
+	}
+
+	for _, name := range []string{"$tv0", "&signer"} {
+		tag, _ := ParseTypeTag(name)
+		if _, err := tag.MarshalBCS(); err == nil {
+			t.Errorf("%s MarshalBCS(), want error", name)
+		}
+	}
+	var got TypeTag
+	if err := got.UnmarshalBCS(append(data, 0)); err == nil {
+		t.Errorf("UnmarshalBCS() with trailing byte, want error")
+	}
+}
+
+func TestStructTag_JSON(t *testing.T) {
+	tag, err := ParseMoveStructTag("0x1::coin::CoinStore<0xabc::coin::T>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(struct{ Tag StructTag }{tag})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var name struct{ Tag string }
+	if err := json.Unmarshal(data, &name); err != nil {
+		t.Fatal(err)
+	}
+	if want := "0x1::coin::CoinStore<0x0000000000000000000000000000000000000000000000000000000000000abc::coin::T>"; name.Tag != want {
+		t.Errorf("json.Marshal() = %s, want %s", name.Tag, want)
+	}
+	var got struct{ Tag StructTag }
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got.Tag, tag) {
+		t.Errorf("json round trip = %s, want %s", got.Tag.GetFullName(), tag.GetFullName())
+	}
+	var bad TypeTag
+	if err := json.Unmarshal([]byte(`"u8foo"`), &bad); err == nil {
+		t.Errorf("json.Unmarshal(u8foo), want error")
+	}
+}
@@ -1,18 +1,130 @@
 package types
 
-import "math/big"
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+)
 
 type TokenType interface {
 	GetFullName() string
 }
 
+// CoinTag classify a coin
+type CoinTag string
+
+const (
+	StableCoinTag  CoinTag = "stable"
+	LPCoinTag      CoinTag = "lp"
+	WrappedCoinTag CoinTag = "wrapped"
+)
+
 type CoinInfo struct {
 	Name      string
 	Decimals  int
 	Symbol    string
 	TokenType TokenType
+	// Unverified is true for coins found on chain which are not in a curated coin list
+	Unverified bool
+	// OfficialSymbol is the symbol of the original coin, eg. USDC for every bridged USDC
+	OfficialSymbol string
+	LogoUrl        string
+	ProjectUrl     string
+	CoingeckoId    string
+	Tags           []CoinTag
+	// Bridge is the bridge the coin comes from, eg. wormhole, empty for native coins
+	Bridge string
+	// Extensions is the other key values of the coin list
+	Extensions map[string]string
+}
+
+// IsVerified is true for coins of a curated coin list
+func (c CoinInfo) IsVerified() bool {
+	return !c.Unverified
+}
+
+func (c CoinInfo) HasTag(tag CoinTag) bool {
+	for _, t := range c.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
 }
 
 type Coin struct {
 	Value *big.Int
 }
+
+// UnmarshalJSON decode the move 0x1::coin::Coin resource, eg. {"value": "100"}
+func (c *Coin) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Value Uint `json:"value"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	if !raw.Value.IsSet() {
+		return fmt.Errorf("coin without value: %s", string(data))
+	}
+	c.Value = raw.Value.Int
+	return nil
+}
+
+// IsSet report whether the coin was present in the json
+func (c Coin) IsSet() bool {
+	return c.Value != nil
+}
+
+// Uint is a move u64/u128 number, which is encoded as decimal string by the REST api
+type Uint struct {
+	*big.Int
+}
+
+func (u *Uint) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("unsigned integer should be string: %s", string(data))
+	}
+	v, ok := big.NewInt(0).SetString(s, 10)
+	if !ok || v.Sign() < 0 {
+		return fmt.Errorf("invalid unsigned integer: %s", s)
+	}
+	u.Int = v
+	return nil
+}
+
+// BigInt return the value, a missing field is zero, use IsSet or RequireFields to reject it
+func (u Uint) BigInt() *big.Int {
+	if u.Int == nil {
+		return big.NewInt(0)
+	}
+	return u.Int
+}
+
+// IsSet report whether the field was present in the json
+func (u Uint) IsSet() bool {
+	return u.Int != nil
+}
+
+// Field is a json field which knows whether it was present, eg. Uint and Coin
+type Field interface {
+	IsSet() bool
+}
+
+// RequireFields return an error naming the fields which were not present, by json name
+func RequireFields(fields map[string]Field) error {
+	missing := make([]string, 0)
+	for name, field := range fields {
+		if !field.IsSet() {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	sort.Strings(missing)
+	return fmt.Errorf("missing fields: %s", strings.Join(missing, ", "))
+}
@@ -0,0 +1,57 @@
+package types
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestCoin_UnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    string
+		wantErr bool
+	}{
+		{name: "u64", data: `{"value":"100"}`, want: "100"},
+		{name: "u128", data: `{"value":"340282366920938463463374607431768211455"}`, want: "340282366920938463463374607431768211455"},
+		{name: "number", data: `{"value":100}`, wantErr: true},
+		{name: "negative", data: `{"value":"-1"}`, wantErr: true},
+		{name: "not number", data: `{"value":"abc"}`, wantErr: true},
+		{name: "missing value", data: `{}`, wantErr: true},
+		{name: "null value", data: `{"value":null}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var c Coin
+			err := json.Unmarshal([]byte(tt.data), &c)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Coin.UnmarshalJSON() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err == nil && c.Value.String() != tt.want {
+				t.Errorf("Coin.UnmarshalJSON() = %v, want %v", c.Value, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequireFields(t *testing.T) {
+	var data struct {
+		A Uint `json:"a"`
+		B Uint `json:"b"`
+		C Coin `json:"c"`
+	}
+	if err := json.Unmarshal([]byte(`{"a":"0"}`), &data); err != nil {
+		t.Fatal(err)
+	}
+	if !data.A.IsSet() || data.B.IsSet() || data.C.IsSet() {
+		t.Errorf("IsSet() = %t, %t, %t, want true, false, false", data.A.IsSet(), data.B.IsSet(), data.C.IsSet())
+	}
+	err := RequireFields(map[string]Field{"a": data.A, "b": data.B, "c": data.C})
+	if err == nil || err.Error() != "missing fields: b, c" {
+		t.Errorf("RequireFields() = %v, want missing fields: b, c", err)
+	}
+	if err = RequireFields(map[string]Field{"a": data.A}); err != nil {
+		t.Errorf("RequireFields() = %v", err)
+	}
+}
@@ -0,0 +1,150 @@
+package types
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// AccountAddress is a 32 bytes account address, it is always valid once parsed
+type AccountAddress [addressLength / 2]byte
+
+// ParseAccountAddress parse an address with or without 0x and leading zeros
+func ParseAccountAddress(address string) (AccountAddress, error) {
+	var result AccountAddress
+	canonical, err := ParseAddress(address)
+	if err != nil {
+		return result, err
+	}
+	digits := canonical[2:]
+	_, err = hex.Decode(result[:], []byte(strings.Repeat("0", addressLength-len(digits))+digits))
+	return result, err
+}
+
+// MustParseAccountAddress is ParseAccountAddress which panics on error, for constant addresses
+func MustParseAccountAddress(address string) AccountAddress {
+	result, err := ParseAccountAddress(address)
+	if err != nil {
+		panic(err)
+	}
+	return result
+}
+
+// String return the canonical form of the address, eg. 0x1
+func (a AccountAddress) String() string {
+	return NormalizeAddress("0x" + hex.EncodeToString(a[:]))
+}
+
+func (a AccountAddress) MarshalText() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+func (a *AccountAddress) UnmarshalText(text []byte) error {
+	address, err := ParseAccountAddress(string(text))
+	if err != nil {
+		return err
+	}
+	*a = address
+	return nil
+}
+
+// ModuleId is a move module, eg. 0x1::coin
+type ModuleId struct {
+	Address AccountAddress
+	Name    string
+}
+
+// NewModuleId validate the address and module name
+func NewModuleId(address, name string) (ModuleId, error) {
+	accountAddress, err := ParseAccountAddress(address)
+	if err != nil {
+		return ModuleId{}, err
+	}
+	if !identifierReg.MatchString(name) {
+		return ModuleId{}, fmt.Errorf("invalid module name: %s", name)
+	}
+	return ModuleId{Address: accountAddress, Name: name}, nil
+}
+
+// ParseModuleId parse address::module
+func ParseModuleId(id string) (ModuleId, error) {
+	address, name, ok := splitByDoubleColon(id)
+	if !ok {
+		return ModuleId{}, fmt.Errorf("invalid module id: %s", id)
+	}
+	return NewModuleId(address, name)
+}
+
+func (m ModuleId) String() string {
+	return fmt.Sprintf("%s::%s", m.Address, m.Name)
+}
+
+// Function return the function name of the module
+func (m ModuleId) Function(name string) (FunctionId, error) {
+	if !identifierReg.MatchString(name) {
+		return FunctionId{}, fmt.Errorf("invalid function name: %s", name)
+	}
+	return FunctionId{Module: m, Name: name}, nil
+}
+
+// FunctionId is a move function, eg. 0x1::coin::transfer
+type FunctionId struct {
+	Module ModuleId
+	Name   string
+}
+
+// NewFunctionId validate the address, module name and function name
+func NewFunctionId(address, module, name string) (FunctionId, error) {
+	moduleId, err := NewModuleId(address, module)
+	if err != nil {
+		return FunctionId{}, err
+	}
+	return moduleId.Function(name)
+}
+
+// ParseFunctionId parse address::module::function
+func ParseFunctionId(id string) (FunctionId, error) {
+	address, rest, ok := splitByDoubleColon(id)
+	if !ok {
+		return FunctionId{}, fmt.Errorf("invalid function id: %s", id)
+	}
+	module, name, ok := splitByDoubleColon(rest)
+	if !ok {
+		return FunctionId{}, fmt.Errorf("invalid function id: %s", id)
+	}
+	return NewFunctionId(address, module, name)
+}
+
+// MustParseFunctionId is ParseFunctionId which panics on error, for constant functions
+func MustParseFunctionId(id string) FunctionId {
+	result, err := ParseFunctionId(id)
+	if err != nil {
+		panic(err)
+	}
+	return result
+}
+
+// IsZero is true for the zero value, which is not a valid function
+func (f FunctionId) IsZero() bool {
+	return f == FunctionId{}
+}
+
+func (f FunctionId) String() string {
+	if f.IsZero() {
+		return ""
+	}
+	return fmt.Sprintf("%s::%s", f.Module, f.Name)
+}
+
+func (f FunctionId) MarshalText() ([]byte, error) {
+	return []byte(f.String()), nil
+}
+
+func (f *FunctionId) UnmarshalText(text []byte) error {
+	id, err := ParseFunctionId(string(text))
+	if err != nil {
+		return err
+	}
+	*f = id
+	return nil
+}
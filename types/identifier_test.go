@@ -0,0 +1,52 @@
+package types
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseFunctionId(t *testing.T) {
+	tests := []struct {
+		id      string
+		want    string
+		wantErr bool
+	}{
+		{"0x1::coin::transfer", "0x1::coin::transfer", false},
+		{"0x0001::coin::transfer", "0x1::coin::transfer", false},
+		{"0xABC::amm::swap_2", "0x0000000000000000000000000000000000000000000000000000000000000abc::amm::swap_2", false},
+		{"0x1::coin", "", true},
+		{"0x1::coin::", "", true},
+		{"0x1::2coin::transfer", "", true},
+		{"0x1::coin::transfer::x", "", true},
+		{"0xg::coin::transfer", "", true},
+		{"::coin::transfer", "", true},
+	}
+	for _, tt := range tests {
+		got, err := ParseFunctionId(tt.id)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseFunctionId(%q) error = %v, wantErr %v", tt.id, err, tt.wantErr)
+			continue
+		}
+		if got.String() != tt.want {
+			t.Errorf("ParseFunctionId(%q) = %q, want %q", tt.id, got, tt.want)
+		}
+	}
+}
+
+func TestEntryFunctionPayload_JSON(t *testing.T) {
+	payload := EntryFunctionPayload{Function: MustParseFunctionId("0x1::coin::transfer")}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got EntryFunctionPayload
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Function != payload.Function || payload.ToAptosPayload().Function != "0x1::coin::transfer" {
+		t.Errorf("Function = %s, want %s", got.Function, payload.Function)
+	}
+	if err := json.Unmarshal([]byte(`{"Function":"0x1::coin"}`), &got); err == nil {
+		t.Errorf("Unmarshal() of bad function, want error")
+	}
+}
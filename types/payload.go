@@ -1,22 +1,26 @@
 package types
 
 import (
-	"fmt"
-
 	"github.com/coming-chat/go-aptos/aptostypes"
 )
 
 const ModuleAddress = "0x89576037b3cc0b89645ea393a47787bb348272c76d6941c574b053672b848039"
 
+var (
+	oneStepRouteFunction   = MustParseFunctionId(ModuleAddress + "::aggregator::one_step_route")
+	twoStepRouteFunction   = MustParseFunctionId(ModuleAddress + "::aggregator::two_step_route")
+	threeStepRouteFunction = MustParseFunctionId(ModuleAddress + "::aggregator::three_step_route")
+)
+
 type EntryFunctionPayload struct {
-	Function string
+	Function FunctionId
 	TypeArgs []string
 	Args     []interface{}
 }
 
 func (p EntryFunctionPayload) ToAptosPayload() *aptostypes.Payload {
 	return &aptostypes.Payload{
-		Function:      p.Function,
+		Function:      p.Function.String(),
 		TypeArguments: p.TypeArgs,
 		Arguments:     p.Args,
 	}
@@ -36,7 +40,7 @@ func BuildPayloadOneStepRoute(
 		typeArgs[i] = item.GetFullName()
 	}
 	return EntryFunctionPayload{
-		Function: fmt.Sprintf("%s::%s::%s", ModuleAddress, "aggregator", "one_step_route"),
+		Function: oneStepRouteFunction,
 		TypeArgs: typeArgs,
 		Args: []interface{}{
 			firstDexType,
@@ -65,7 +69,7 @@ func BuildPayloadTwoStepRoute(
 		typeArgs[i] = item.GetFullName()
 	}
 	return EntryFunctionPayload{
-		Function: fmt.Sprintf("%s::%s::%s", ModuleAddress, "aggregator", "two_step_route"),
+		Function: twoStepRouteFunction,
 		TypeArgs: typeArgs,
 		Args: []interface{}{
 			firstDexType,
@@ -100,7 +104,7 @@ func BuildPayloadThreeStepRoute(
 		typeArgs[i] = item.GetFullName()
 	}
 	return EntryFunctionPayload{
-		Function: fmt.Sprintf("%s::%s::%s", ModuleAddress, "aggregator", "three_step_route"),
+		Function: threeStepRouteFunction,
 		TypeArgs: typeArgs,
 		Args: []interface{}{
 			firstDexType,
@@ -11,8 +11,11 @@ import (
 const (
 	Bool    StringTokenType = "bool"
 	U8      StringTokenType = "u8"
+	U16     StringTokenType = "u16"
+	U32     StringTokenType = "u32"
 	U64     StringTokenType = "u64"
 	U128    StringTokenType = "u128"
+	U256    StringTokenType = "u256"
 	Address StringTokenType = "address"
 	Signer  StringTokenType = "signer"
 )
@@ -23,21 +26,34 @@ func (s StringTokenType) GetFullName() string {
 	return string(s)
 }
 
-var atomicTypeTags = []StringTokenType{Bool, U8, U64, U128, Address, Signer}
+var atomicTypeTags = []StringTokenType{Bool, U8, U16, U32, U64, U128, U256, Address, Signer}
 var structTagNameReg *regexp.Regexp = regexp.MustCompile("^[a-zA-Z_][a-zA-Z_0-9]*<")
+var identifierReg *regexp.Regexp = regexp.MustCompile("^[a-zA-Z_][a-zA-Z_0-9]*$")
 
 type TypeTag struct {
 	AtomicTypeTag *AtomicTypeTag
 	StructTag     *StructTag
 	VectorTag     *VectorTag
 	TypeParamIdx  *TypeParamIdx
+	ReferenceTag  *ReferenceTag
 }
 
 func (t TypeTag) isEmpty() bool {
 	return t.AtomicTypeTag == nil &&
 		t.StructTag == nil &&
 		t.VectorTag == nil &&
-		t.TypeParamIdx == nil
+		t.TypeParamIdx == nil &&
+		t.ReferenceTag == nil
+}
+
+// GetFullName return the canonical name of the type
+func (t TypeTag) GetFullName() string {
+	return getTypeTagFullName(t)
+}
+
+// ParseTypeTag parse any Move type name, eg. u64, vector<u8>, &signer or 0x1::coin::Coin<0x1::aptos_coin::AptosCoin>
+func ParseTypeTag(name string) (TypeTag, error) {
+	return parseTypeTagOrError(name)
 }
 
 type AtomicTypeTag struct {
@@ -52,6 +68,12 @@ type TypeParamIdx struct {
 	ParamIdx int
 }
 
+// ReferenceTag is a reference type of function params, eg. &signer or &mut T
+type ReferenceTag struct {
+	Mutable   bool
+	TypeParam TypeTag
+}
+
 type StructTag struct {
 	Address    string
 	Module     string
@@ -59,9 +81,19 @@ type StructTag struct {
 	TypeParams []TypeTag
 }
 
+// GetFullName return the canonical name, with normalized addresses and type params separated by ", "
 func (t *StructTag) GetFullName() string {
 	typeParamStr := getTypeParamsString(t.TypeParams)
-	return fmt.Sprintf("%s::%s::%s%s", t.Address, t.Module, t.Name, typeParamStr)
+	return fmt.Sprintf("%s::%s::%s%s", NormalizeAddress(t.Address), t.Module, t.Name, typeParamStr)
+}
+
+// CanonicalTypeName return the canonical full name of a type name, or name itself if it can not be parsed
+func CanonicalTypeName(name string) string {
+	tag, err := parseTypeTagOrError(name)
+	if err != nil {
+		return name
+	}
+	return getTypeTagFullName(tag)
 }
 
 func getTypeTagFullName(typeTag TypeTag) string {
@@ -73,6 +105,11 @@ func getTypeTagFullName(typeTag TypeTag) string {
 		return fmt.Sprintf("$tv%d", typeTag.TypeParamIdx.ParamIdx)
 	} else if typeTag.AtomicTypeTag != nil {
 		return typeTag.AtomicTypeTag.Name
+	} else if typeTag.ReferenceTag != nil {
+		if typeTag.ReferenceTag.Mutable {
+			return "&mut " + getTypeTagFullName(typeTag.ReferenceTag.TypeParam)
+		}
+		return "&" + getTypeTagFullName(typeTag.ReferenceTag.TypeParam)
 	} else {
 		return ""
 	}
@@ -102,7 +139,7 @@ func ParseMoveStructTag(tag string) (StructTag, error) {
 }
 
 func parseTypeTagOrError(name string) (TypeTag, error) {
-	tag, remaining, err := parseTypeTag(name)
+	tag, remaining, err := parseTypeTag(stripSpaces(name))
 	if err != nil {
 		return TypeTag{}, err
 	}
@@ -112,13 +149,48 @@ func parseTypeTagOrError(name string) (TypeTag, error) {
 	return tag, nil
 }
 
+// stripSpaces drop whitespace so any spacing parses the same,
+// only a single space between two words is kept, as in &mut T
+func stripSpaces(name string) string {
+	var b strings.Builder
+	b.Grow(len(name))
+	space := false
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if c == ' ' || c == '\t' || c == '\n' || c == '\r' {
+			space = true
+			continue
+		}
+		if space && b.Len() > 0 && isIdentChar(b.String()[b.Len()-1]) && isIdentChar(c) {
+			b.WriteByte(' ')
+		}
+		space = false
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+func isIdentChar(c byte) bool {
+	return c == '_' || (c >= '0' && c <= '9') || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
 func parseTypeTag(name string) (result TypeTag, remain string, err error) {
 	var (
-		atomicResult *AtomicTypeTag
-		vectorResult *VectorTag
-		structResult *StructTag
-		tvResult     *TypeParamIdx
+		atomicResult    *AtomicTypeTag
+		vectorResult    *VectorTag
+		structResult    *StructTag
+		tvResult        *TypeParamIdx
+		referenceResult *ReferenceTag
 	)
+	referenceResult, remain, err = parseReferenceTag(name)
+	if err != nil {
+		return
+	}
+	if referenceResult != nil {
+		result.ReferenceTag = referenceResult
+		return
+	}
+
 	atomicResult, remain = parseAtomicTag(name)
 	if atomicResult != nil {
 		result.AtomicTypeTag = atomicResult
@@ -134,21 +206,22 @@ func parseTypeTag(name string) (result TypeTag, remain string, err error) {
 		return
 	}
 
-	structResult, remain, err = parseQualifiedStructTag(name)
+	// type parameters go before structs, a later param may contain ::
+	tvResult, remain, err = parseTypeParameter(name)
 	if err != nil {
 		return
 	}
-	if structResult != nil {
-		result.StructTag = structResult
+	if tvResult != nil {
+		result.TypeParamIdx = tvResult
 		return
 	}
 
-	tvResult, remain, err = parseTypeParameter(name)
+	structResult, remain, err = parseQualifiedStructTag(name)
 	if err != nil {
 		return
 	}
-	if tvResult != nil {
-		result.TypeParamIdx = tvResult
+	if structResult != nil {
+		result.StructTag = structResult
 		return
 	}
 
@@ -157,23 +230,44 @@ func parseTypeTag(name string) (result TypeTag, remain string, err error) {
 }
 
 func parseAtomicTag(name string) (*AtomicTypeTag, string) {
+	// the whole word must match, so u8foo is not u8
+	end := 0
+	for end < len(name) && isIdentChar(name[end]) {
+		end++
+	}
+	word := name[:end]
 	for _, t := range atomicTypeTags {
-		tag := string(t)
-		if strings.HasPrefix(name, tag) {
-			if len(name) == len(tag) {
-				return &AtomicTypeTag{
-					Name: tag,
-				}, ""
-			} else if name[len(tag)] == ',' || name[len(tag)] == '>' {
-				return &AtomicTypeTag{
-					Name: tag,
-				}, name[len(tag):]
-			}
+		if word == string(t) {
+			return &AtomicTypeTag{
+				Name: word,
+			}, name[end:]
 		}
 	}
 	return nil, name
 }
 
+func parseReferenceTag(name string) (*ReferenceTag, string, error) {
+	if !strings.HasPrefix(name, "&") {
+		return nil, name, nil
+	}
+	mutable := strings.HasPrefix(name, "&mut ")
+	rest := name[1:]
+	if mutable {
+		rest = name[5:]
+	}
+	if strings.HasPrefix(rest, "&") {
+		return nil, "", fmt.Errorf("reference to reference: %s", name)
+	}
+	typeParam, remain, err := parseTypeTag(rest)
+	if err != nil {
+		return nil, "", err
+	}
+	return &ReferenceTag{
+		Mutable:   mutable,
+		TypeParam: typeParam,
+	}, remain, nil
+}
+
 func parseVectorTag(name string) (*VectorTag, string, error) {
 	if !strings.HasPrefix(name, "vector<") {
 		return nil, name, nil
@@ -195,8 +289,18 @@ func parseQualifiedStructTag(name string) (*StructTag, string, error) {
 	if !strings.Contains(name, "::") {
 		return nil, name, nil
 	}
-	address, withoutAddress := splitByDoubleColon(name)
-	module, withoutModule := splitByDoubleColon(withoutAddress)
+	rawAddress, withoutAddress, ok := splitByDoubleColon(name)
+	if !ok {
+		return nil, "", fmt.Errorf("badly formatted struct name: %s", name)
+	}
+	address, err := ParseAddress(rawAddress)
+	if err != nil {
+		return nil, "", err
+	}
+	module, withoutModule, ok := splitByDoubleColon(withoutAddress)
+	if !ok || !identifierReg.MatchString(module) {
+		return nil, "", fmt.Errorf("badly formatted struct name: %s", name)
+	}
 	if structTagNameReg.Match([]byte(withoutModule)) {
 		leftBracketIdx := strings.Index(withoutModule, "<")
 		structName := withoutModule[0:leftBracketIdx]
@@ -233,19 +337,12 @@ func parseQualifiedStructTag(name string) (*StructTag, string, error) {
 			}
 		}
 	} else {
-		commaIdx := strings.Index(withoutModule, ",")
-		brackIdx := strings.Index(withoutModule, ">")
-		if commaIdx == -1 && brackIdx == -1 {
-			return &StructTag{
-				Address:    address,
-				Module:     module,
-				Name:       withoutModule,
-				TypeParams: []TypeTag{},
-			}, "", nil
+		separatorIdx := strings.IndexAny(withoutModule, ",>")
+		if separatorIdx == -1 {
+			separatorIdx = len(withoutModule)
 		}
-		separatorIdx := brackIdx
-		if commaIdx > -1 {
-			separatorIdx = min(commaIdx, brackIdx)
+		if !identifierReg.MatchString(withoutModule[0:separatorIdx]) {
+			return nil, "", fmt.Errorf("badly formatted struct name: %s", name)
 		}
 		return &StructTag{
 			Address:    address,
@@ -271,23 +368,20 @@ func parseTypeParameter(name string) (*TypeParamIdx, string, error) {
 	if idx == 3 {
 		return nil, name, fmt.Errorf("failed to find number after $tv in: %s", name)
 	}
-	paramIdx, err := strconv.ParseInt(name[3:idx-3], 10, 64)
+	paramIdx, err := strconv.ParseInt(name[3:idx], 10, 64)
 	if err != nil {
-		return nil, name, fmt.Errorf("paramIdx is not integer: %s", name[3:idx-3])
+		return nil, name, fmt.Errorf("paramIdx is not integer: %s", name[3:idx])
 	}
 	return &TypeParamIdx{
 		ParamIdx: int(paramIdx),
 	}, name[idx:], nil
 }
 
-func splitByDoubleColon(name string) (string, string) {
+// splitByDoubleColon split name at the first ::, false if name has no ::
+func splitByDoubleColon(name string) (string, string, bool) {
 	endIdx := strings.Index(name, "::")
-	return name[0:endIdx], name[endIdx+2:]
-}
-
-func min(a, b int) int {
-	if a < b {
-		return a
+	if endIdx == -1 {
+		return "", "", false
 	}
-	return b
+	return name[0:endIdx], name[endIdx+2:], true
 }
@@ -1,6 +1,7 @@
 package types
 
 import (
+	"math/rand"
 	"reflect"
 	"testing"
 )
@@ -185,3 +186,86 @@ func TestStructTag_GetFullName(t *testing.T) {
 		})
 	}
 }
+
+// randomTypeTag generate a random valid type tag nested at most depth levels
+func randomTypeTag(r *rand.Rand, depth int) TypeTag {
+	kind := r.Intn(4)
+	if depth <= 0 {
+		kind = 0
+	}
+	switch kind {
+	case 0:
+		return TypeTag{AtomicTypeTag: &AtomicTypeTag{Name: string(atomicTypeTags[r.Intn(len(atomicTypeTags))])}}
+	case 1:
+		return TypeTag{VectorTag: &VectorTag{TypeParam: randomTypeTag(r, depth-1)}}
+	case 2:
+		return TypeTag{TypeParamIdx: &TypeParamIdx{ParamIdx: r.Intn(20)}}
+	default:
+		tag := randomStructTag(r, depth-1)
+		return TypeTag{StructTag: &tag}
+	}
+}
+
+func randomStructTag(r *rand.Rand, depth int) StructTag {
+	addresses := []string{"0x1", "0x3", "0x5e156f1207d0ebfa19a9eeff00d62a282278fb8719f4fab3a586a0a2c0fffbea"}
+	params := make([]TypeTag, r.Intn(3))
+	for i := range params {
+		params[i] = randomTypeTag(r, depth)
+	}
+	return StructTag{
+		Address:    addresses[r.Intn(len(addresses))],
+		Module:     randomIdentifier(r),
+		Name:       randomIdentifier(r),
+		TypeParams: params,
+	}
+}
+
+func randomIdentifier(r *rand.Rand) string {
+	const first = "abcxyzABCXYZ_"
+	const rest = first + "0123456789"
+	b := []byte{first[r.Intn(len(first))]}
+	for i := r.Intn(8); i > 0; i-- {
+		b = append(b, rest[r.Intn(len(rest))])
+	}
+	return string(b)
+}
+
+func TestParseMoveStructTag_RoundTrip(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 2000; i++ {
+		tag := randomStructTag(r, 3)
+		got, err := ParseMoveStructTag(tag.GetFullName())
+		if err != nil {
+			t.Fatalf("ParseMoveStructTag(%s) error = %v", tag.GetFullName(), err)
+		}
+		if !reflect.DeepEqual(got, tag) {
+			t.Fatalf("ParseMoveStructTag(%s) = %s", tag.GetFullName(), got.GetFullName())
+		}
+	}
+}
+
+func FuzzParseTypeTag(f *testing.F) {
+	for _, seed := range []string{
+		"u8", "u8foo", "vector<u64>", "$tv0", "$tv", "&mut signer",
+		"0x1::m::S", "0x1::m", "0x1::m::S,", "0x1::m::S<", "0x1::m::S<u8,>", "::", "0x1::::",
+		"0x1::coin::CoinStore<0x1::aptos_coin::AptosCoin>",
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, name string) {
+		// must never panic
+		_, _, _ = parseTypeTag(name)
+		tag, err := ParseTypeTag(name)
+		if err != nil {
+			return
+		}
+		// a parsed tag round trips through its canonical name
+		again, err := ParseTypeTag(tag.GetFullName())
+		if err != nil {
+			t.Fatalf("ParseTypeTag(%q) error = %v, parsed from %q", tag.GetFullName(), err, name)
+		}
+		if again.GetFullName() != tag.GetFullName() {
+			t.Fatalf("ParseTypeTag(%q) = %q, want %q", tag.GetFullName(), again.GetFullName(), tag.GetFullName())
+		}
+	})
+}